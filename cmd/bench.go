@@ -0,0 +1,275 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/jamf/regatta/regattapb"
+	"github.com/spf13/cobra"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// maxTrackedLatency is the highest latency tracked by latency histograms, higher latencies are tracked as this latency.
+const maxTrackedLatency = time.Minute
+
+const (
+	putWorkloadName   = "put"
+	rangeWorkloadName = "range"
+	mixedWorkloadName = "mixed"
+)
+
+var (
+	benchWorkload     = workloadType(putWorkloadName)
+	benchConcurrency  int
+	benchKeys         int
+	benchKeyPrefix    string
+	benchValueSize    int
+	benchValueSizeMax int
+	benchReadRatio    float64
+	benchRate         float64
+	benchDuration     time.Duration
+)
+
+func init() {
+	Bench.Flags().Var(&benchWorkload, "workload", `type of workload, allowed values: "put", "range" and "mixed"`)
+	Bench.RegisterFlagCompletionFunc("workload", workloadTypeCompletion)
+	Bench.Flags().IntVar(&benchConcurrency, "concurrency", 10, "number of concurrent workers")
+	Bench.Flags().IntVar(&benchKeys, "keys", 1000, "number of distinct keys used by the workload")
+	Bench.Flags().StringVar(&benchKeyPrefix, "key-prefix", "bench-", "prefix of keys used by the workload")
+	Bench.Flags().IntVar(&benchValueSize, "value-size", 128, "size of put values in bytes")
+	Bench.Flags().IntVar(&benchValueSizeMax, "value-size-max", 0, "maximum size of put values in bytes, when greater than --value-size, sizes are uniformly distributed between the two")
+	Bench.Flags().Float64Var(&benchReadRatio, "read-ratio", 0.5, "ratio of range requests in mixed workload")
+	Bench.Flags().Float64Var(&benchRate, "rate", 0, "maximum number of requests per second, 0 means unlimited")
+	Bench.Flags().DurationVar(&benchDuration, "duration", 10*time.Second, "duration of the benchmark")
+}
+
+// Bench is a subcommand used for benchmarking Regatta.
+var Bench = cobra.Command{
+	Use:   "bench <table>",
+	Short: "Benchmark Regatta store",
+	Long: "Benchmarks Regatta store by generating load using Put and Range queries as defined in API (https://engineering.jamf.com/regatta/api/).\n" +
+		"Workload \"put\" puts random values under random keys, workload \"range\" retrieves random keys and workload \"mixed\" combines both " +
+		"with the ratio of range requests given by --read-ratio flag. Keys are chosen from the key space given by --keys and --key-prefix flags.\n" +
+		"The benchmark runs for the duration given by --duration flag or until interrupted, then the report containing number of requests, errors, " +
+		"throughput and latency percentiles is printed as JSON object. Latency percentiles are tracked up to 1 minute, " +
+		"higher latencies are counted in \"over_max_tracked\" field and tracked as 1 minute, the maximal latency is exact.\n" +
+		"Requests are not retried, unless --retries flag is provided, so that errors and latencies are reported for each request as sent.\n" +
+		"Beware that put and mixed workloads overwrite data in the table.",
+	Example: "regatta-client bench table\n" +
+		"regatta-client bench --workload mixed --concurrency 50 --rate 1000 --duration 1m table\n" +
+		"regatta-client bench --value-size 64 --value-size-max 4096 --compress zstd table",
+	Args: cobra.MatchAll(cobra.ExactArgs(1)),
+	Run: func(cmd *cobra.Command, args []string) {
+		if benchConcurrency < 1 || benchKeys < 1 {
+			printError(cmd, "There was an error while decoding parameters. Both --concurrency and --keys must be positive numbers.")
+			return
+		}
+		if benchValueSize < 0 || (benchValueSizeMax != 0 && benchValueSizeMax < benchValueSize) {
+			printError(cmd, "There was an error while decoding parameters. The --value-size must not be negative and --value-size-max must be either 0 or at least --value-size.")
+			return
+		}
+
+		client, err := createClient()
+		if err != nil {
+			printError(cmd, "There was an error, while establishing connection to Regatta.", err)
+			return
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		ctx, cancel := context.WithTimeout(ctx, benchDuration)
+		defer cancel()
+
+		var opts []grpc.CallOption
+		if !cmd.Flags().Changed("retries") {
+			// retried requests would be reported with latencies including the backoff
+			opts = append(opts, noRetryCallOption{})
+		}
+		report := runBenchmark(ctx, client, []byte(args[0]), opts...)
+		marshal, _ := json.Marshal(report)
+		cmd.Println(string(marshal))
+	},
+}
+
+type workloadType string
+
+func (w *workloadType) String() string {
+	return string(*w)
+}
+
+func (w *workloadType) Set(v string) error {
+	switch v {
+	case putWorkloadName, rangeWorkloadName, mixedWorkloadName:
+		*w = workloadType(v)
+		return nil
+	default:
+		return errors.New(`must be one of "put", "range" or "mixed"`)
+	}
+}
+
+func (w *workloadType) Type() string {
+	return "workloadType"
+}
+
+func workloadTypeCompletion(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	return []string{
+		"put\tput random values under random keys",
+		"range\tretrieve random keys",
+		"mixed\tcombination of put and range requests",
+	}, cobra.ShellCompDirectiveDefault
+}
+
+type benchReport struct {
+	Workload   string           `json:"workload"`
+	Requests   int64            `json:"requests"`
+	Errors     int64            `json:"errors"`
+	ErrorCodes map[string]int64 `json:"error_codes,omitempty"`
+	Duration   string           `json:"duration"`
+	Throughput float64          `json:"throughput"`
+	Latency    latencyReport    `json:"latency"`
+}
+
+type latencyReport struct {
+	Min  string `json:"min"`
+	Mean string `json:"mean"`
+	P50  string `json:"p50"`
+	P90  string `json:"p90"`
+	P99  string `json:"p99"`
+	P999 string `json:"p999"`
+	Max  string `json:"max"`
+	// OverMaxTracked is number of latencies higher than maxTrackedLatency, which are tracked as maxTrackedLatency by percentiles.
+	OverMaxTracked int64 `json:"over_max_tracked,omitempty"`
+}
+
+// benchWorker generates the workload and records latencies of successful requests in microseconds.
+type benchWorker struct {
+	client     regattapb.KVClient
+	table      []byte
+	opts       []grpc.CallOption
+	limiter    *rate.Limiter
+	rand       *rand.Rand
+	histogram  *hdrhistogram.Histogram
+	maxLatency time.Duration
+	overMax    int64
+	errorCodes map[string]int64
+}
+
+func runBenchmark(ctx context.Context, client regattapb.KVClient, table []byte, opts ...grpc.CallOption) benchReport {
+	limiter := rate.NewLimiter(rate.Inf, 0)
+	if benchRate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(benchRate), 1)
+	}
+
+	workers := make([]*benchWorker, benchConcurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range workers {
+		workers[i] = &benchWorker{
+			client:  client,
+			table:   table,
+			opts:    opts,
+			limiter: limiter,
+			// nolint:gosec
+			rand:       rand.New(rand.NewSource(time.Now().UnixNano() + int64(i))),
+			histogram:  hdrhistogram.New(1, maxTrackedLatency.Microseconds(), 3),
+			errorCodes: make(map[string]int64),
+		}
+		wg.Add(1)
+		go func(w *benchWorker) {
+			defer wg.Done()
+			w.run(ctx)
+		}(workers[i])
+	}
+	wg.Wait()
+	return newBenchReport(workers, time.Since(start))
+}
+
+// newBenchReport merges results of all workers into the report.
+func newBenchReport(workers []*benchWorker, elapsed time.Duration) benchReport {
+	histogram := hdrhistogram.New(1, maxTrackedLatency.Microseconds(), 3)
+	report := benchReport{Workload: benchWorkload.String(), ErrorCodes: make(map[string]int64), Duration: elapsed.Round(time.Millisecond).String()}
+	var maxLatency time.Duration
+	for _, w := range workers {
+		histogram.Merge(w.histogram)
+		maxLatency = max(maxLatency, w.maxLatency)
+		report.Latency.OverMaxTracked += w.overMax
+		for code, count := range w.errorCodes {
+			report.ErrorCodes[code] += count
+			report.Errors += count
+		}
+	}
+	report.Requests = histogram.TotalCount() + report.Errors
+	report.Throughput = float64(report.Requests) / elapsed.Seconds()
+	report.Latency.Min = microseconds(histogram.Min())
+	report.Latency.Mean = microseconds(int64(histogram.Mean()))
+	report.Latency.P50 = microseconds(histogram.ValueAtQuantile(50))
+	report.Latency.P90 = microseconds(histogram.ValueAtQuantile(90))
+	report.Latency.P99 = microseconds(histogram.ValueAtQuantile(99))
+	report.Latency.P999 = microseconds(histogram.ValueAtQuantile(99.9))
+	report.Latency.Max = maxLatency.Round(time.Microsecond).String()
+	return report
+}
+
+func (w *benchWorker) run(ctx context.Context) {
+	for {
+		if err := w.limiter.Wait(ctx); err != nil {
+			return
+		}
+		start := time.Now()
+		err := w.request(ctx)
+		if ctx.Err() != nil {
+			// requests interrupted by the end of the benchmark are not counted
+			return
+		}
+		if err != nil {
+			w.errorCodes[status.Code(err).String()]++
+			continue
+		}
+		w.record(time.Since(start))
+	}
+}
+
+// record records the latency, latencies higher than maxTrackedLatency are counted and tracked as maxTrackedLatency.
+func (w *benchWorker) record(latency time.Duration) {
+	w.maxLatency = max(w.maxLatency, latency)
+	if latency > maxTrackedLatency {
+		w.overMax++
+		latency = maxTrackedLatency
+	}
+	_ = w.histogram.RecordValue(latency.Microseconds())
+}
+
+func (w *benchWorker) request(ctx context.Context) error {
+	key := []byte(benchKeyPrefix + strconv.Itoa(w.rand.Intn(benchKeys)))
+	read := benchWorkload == rangeWorkloadName || (benchWorkload == mixedWorkloadName && w.rand.Float64() < benchReadRatio)
+	if read {
+		_, err := w.client.Range(ctx, &regattapb.RangeRequest{Table: w.table, Key: key}, w.opts...)
+		return err
+	}
+	_, err := w.client.Put(ctx, &regattapb.PutRequest{Table: w.table, Key: key, Value: w.value()}, w.opts...)
+	return err
+}
+
+func (w *benchWorker) value() []byte {
+	size := benchValueSize
+	if benchValueSizeMax > benchValueSize {
+		size += w.rand.Intn(benchValueSizeMax - benchValueSize + 1)
+	}
+	value := make([]byte, size)
+	_, _ = w.rand.Read(value)
+	return value
+}
+
+func microseconds(v int64) string {
+	return (time.Duration(v) * time.Microsecond).String()
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/jamf/regatta/regattapb"
+	"github.com/jamf/regatta/regattaserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+func Test_Bench_Mixed(t *testing.T) {
+	defer resetBenchFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, mock.Anything).Return(&regattapb.RangeResponse{}, nil)
+	storage.On("Put", mock.Anything, mock.Anything).Return(&regattapb.PutResponse{}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	buf := new(bytes.Buffer)
+	RootCmd.SetOut(buf)
+	RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "bench", "--workload", "mixed", "--concurrency", "2", "--rate", "100", "--duration", "200ms", "table"})
+	RootCmd.Execute()
+
+	var report benchReport
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+	assert.Equal(t, "mixed", report.Workload)
+	assert.Positive(t, report.Requests)
+	assert.LessOrEqual(t, report.Requests, int64(25))
+	assert.Zero(t, report.Errors)
+	storage.AssertCalled(t, "Range", mock.Anything, mock.Anything)
+	storage.AssertCalled(t, "Put", mock.Anything, mock.Anything)
+}
+
+func Test_Bench_NoRetries(t *testing.T) {
+	defer resetBenchFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+	unavailableKV := &unavailableKVServer{}
+	regattapb.RegisterKVServer(s, unavailableKV)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	buf := new(bytes.Buffer)
+	RootCmd.SetOut(buf)
+	RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "bench", "--workload", "range", "--concurrency", "1", "--rate", "50", "--duration", "200ms", "table"})
+	RootCmd.Execute()
+
+	// each failed request is reported, without being retried
+	var report benchReport
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+	assert.Positive(t, report.Errors)
+	assert.Equal(t, report.Requests, report.Errors)
+	assert.Equal(t, map[string]int64{"Unavailable": report.Errors}, report.ErrorCodes)
+	assert.Equal(t, report.Requests, int64(unavailableKV.calls.Load()))
+}
+
+func Test_Bench_InvalidParameters(t *testing.T) {
+	defer resetBenchFlags()
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "negative value size", args: []string{"--value-size", "-5"}},
+		{name: "maximal value size lower than value size", args: []string{"--value-size", "128", "--value-size-max", "64"}},
+		{name: "zero concurrency", args: []string{"--concurrency", "0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetBenchFlags()
+			buf := new(bytes.Buffer)
+			errBuf := new(bytes.Buffer)
+			RootCmd.SetOut(buf)
+			RootCmd.SetErr(errBuf)
+			defer RootCmd.SetErr(nil)
+			RootCmd.SetArgs(append([]string{"--endpoint", "localhost:8443", "--cert", "test.crt", "bench", "table"}, tt.args...))
+
+			assert.Equal(t, 1, executeCommand())
+			assert.Empty(t, buf.String())
+			assert.Contains(t, errBuf.String(), "There was an error while decoding parameters.")
+		})
+	}
+}
+
+func Test_newBenchReport_OverMaxTracked(t *testing.T) {
+	defer resetBenchFlags()
+
+	w := &benchWorker{histogram: hdrhistogram.New(1, maxTrackedLatency.Microseconds(), 3), errorCodes: map[string]int64{"Unavailable": 1}}
+	w.record(10 * time.Millisecond)
+	w.record(2 * time.Minute)
+
+	report := newBenchReport([]*benchWorker{w}, time.Second)
+
+	assert.Equal(t, int64(3), report.Requests)
+	assert.Equal(t, int64(1), report.Errors)
+	assert.Equal(t, "2m0s", report.Latency.Max)
+	assert.Equal(t, int64(1), report.Latency.OverMaxTracked)
+	p99, err := time.ParseDuration(report.Latency.P99)
+	require.NoError(t, err)
+	assert.InDelta(t, time.Minute, p99, float64(100*time.Millisecond), "latency over the histogram range must not be dropped")
+}
+
+func resetBenchFlags() {
+	benchWorkload = workloadType(putWorkloadName)
+	benchConcurrency = 10
+	benchKeys = 1000
+	benchValueSize = 128
+	benchValueSizeMax = 0
+	benchRate = 0
+	benchDuration = 10 * time.Second
+}
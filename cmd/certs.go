@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// caFileExtensions are extensions of files loaded from CA directory.
+var caFileExtensions = []string{".pem", ".crt", ".cer"}
+
+// newTLSConfig creates TLS config trusting selected CA certificates and checking pinned public keys.
+func newTLSConfig() (*tls.Config, error) {
+	pool, err := certPool()
+	if err != nil {
+		return nil, err
+	}
+	pins, err := parsePins(pinsOption)
+	if err != nil {
+		return nil, err
+	}
+	// nolint:gosec
+	cfg := &tls.Config{RootCAs: pool, InsecureSkipVerify: insecureOption}
+	if len(pins) != 0 {
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			if insecureOption {
+				// the chain is not verified, so only the certificates proven to sign the server certificate can match the pins
+				return verifyPins([][]*x509.Certificate{signedChain(cs.PeerCertificates)}, pins)
+			}
+			return verifyPins(cs.VerifiedChains, pins)
+		}
+	}
+	return cfg, nil
+}
+
+// certPool returns pool of trusted CA certificates, consisting of system CA certificates, unless disabled,
+// and certificates provided by --cert and --ca-dir flags.
+func certPool() (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if !noSystemCAOption {
+		var err error
+		if pool, err = x509.SystemCertPool(); err != nil {
+			return nil, err
+		}
+	}
+	if len(certOption) != 0 {
+		if err := appendCertsFromFile(pool, certOption); err != nil {
+			return nil, err
+		}
+	}
+	if len(caDirOption) != 0 {
+		entries, err := os.ReadDir(caDirOption)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !hasCAFileExtension(entry.Name()) {
+				continue
+			}
+			if err := appendCertsFromFile(pool, filepath.Join(caDirOption, entry.Name())); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return pool, nil
+}
+
+func hasCAFileExtension(name string) bool {
+	for _, ext := range caFileExtensions {
+		if strings.EqualFold(filepath.Ext(name), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendCertsFromFile appends all certificates in PEM file into the pool, unlike x509.CertPool.AppendCertsFromPEM,
+// the file not containing any certificate or containing unparsable certificate is reported as error.
+func appendCertsFromFile(pool *x509.CertPool, file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	certs, err := parsePEMCertificates(data)
+	if err != nil {
+		return fmt.Errorf("invalid CA certificate file %s: %w", file, err)
+	}
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	return nil
+}
+
+func parsePEMCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	blocks := 0
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		blocks++
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse PEM block #%d: %w", blocks, err)
+		}
+		certs = append(certs, cert)
+	}
+	switch {
+	case blocks == 0:
+		return nil, errors.New("no PEM data found")
+	case len(certs) == 0:
+		return nil, errors.New("no certificate found")
+	default:
+		return certs, nil
+	}
+}
+
+// parsePins parses Base64 encoded SHA-256 hashes of public keys, pins can be prefixed with "sha256//".
+func parsePins(pins []string) ([][]byte, error) {
+	parsed := make([][]byte, 0, len(pins))
+	for _, pin := range pins {
+		hash, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, "sha256//"))
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("pin %q is not Base64 encoded SHA-256 hash", pin)
+		}
+		parsed = append(parsed, hash)
+	}
+	return parsed, nil
+}
+
+// verifyPins checks that public key of at least one of the certificates in the chains matches one of the pins.
+// Only verified chains can be checked, because certificates presented by the server, which are not part of the verified chain,
+// can be appended by anyone.
+func verifyPins(chains [][]*x509.Certificate, pins [][]byte) error {
+	for _, chain := range chains {
+		for _, cert := range chain {
+			hash := spkiHash(cert)
+			for _, pin := range pins {
+				if bytes.Equal(hash[:], pin) {
+					return nil
+				}
+			}
+		}
+	}
+	return errors.New("none of the server certificates matches pinned public keys")
+}
+
+// signedChain returns the leading part of the certificates presented by the server, in which each certificate is signed by the following one.
+func signedChain(certs []*x509.Certificate) []*x509.Certificate {
+	for i := 0; i+1 < len(certs); i++ {
+		if certs[i].CheckSignatureFrom(certs[i+1]) != nil {
+			return certs[:i+1]
+		}
+	}
+	return certs
+}
+
+// spkiHash returns SHA-256 hash of the certificate public key (SubjectPublicKeyInfo).
+func spkiHash(cert *x509.Certificate) [sha256.Size]byte {
+	return sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+}
@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jamf/regatta/regattapb"
+	"github.com/jamf/regatta/regattaserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+func Test_parsePEMCertificates(t *testing.T) {
+	testCert, err := os.ReadFile("test.crt")
+	require.NoError(t, err)
+	testKey, err := os.ReadFile("test.key")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		wantCerts int
+		wantErr   string
+	}{
+		{
+			name:      "certificate",
+			data:      testCert,
+			wantCerts: 1,
+		},
+		{
+			name:      "certificate bundle with key",
+			data:      bytes.Join([][]byte{testCert, testKey, testCert}, nil),
+			wantCerts: 2,
+		},
+		{
+			name:    "no PEM data",
+			data:    []byte("not a certificate"),
+			wantErr: "no PEM data found",
+		},
+		{
+			name:    "no certificate",
+			data:    testKey,
+			wantErr: "no certificate found",
+		},
+		{
+			name:    "corrupted certificate",
+			data:    pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("corrupted")}),
+			wantErr: "unable to parse PEM block #1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certs, err := parsePEMCertificates(tt.data)
+
+			if len(tt.wantErr) != 0 {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, certs, tt.wantCerts)
+		})
+	}
+}
+
+func Test_certPool_CADir(t *testing.T) {
+	defer resetTLSFlags()
+	dir := t.TempDir()
+	testCert, err := os.ReadFile("test.crt")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ca.pem"), testCert, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("not a certificate"), 0o600))
+
+	certOption = ""
+	caDirOption = dir
+	noSystemCAOption = true
+	pool, err := certPool()
+	require.NoError(t, err)
+
+	certs, err := parsePEMCertificates(testCert)
+	require.NoError(t, err)
+	_, err = certs[0].Verify(x509.VerifyOptions{Roots: pool, DNSName: "localhost"})
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "invalid.crt"), []byte("not a certificate"), 0o600))
+	_, err = certPool()
+	require.ErrorContains(t, err, "invalid.crt: no PEM data found")
+}
+
+func Test_Range_PinSHA256_AppendedCertificate(t *testing.T) {
+	resetRangeFlags()
+	defer resetTLSFlags()
+	defer resetEndpointFlags()
+
+	// the server presents its certificate followed by an unrelated certificate, whose public key is pinned
+	appended, appendedPin := generateCertificate(t)
+	cfg := generateTLSConfig()
+	cfg.Certificates[0].Certificate = append(cfg.Certificates[0].Certificate, appended)
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(cfg)))
+	storage := new(mockKVService)
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	for _, args := range [][]string{{"--cert", "test.crt"}, {"--insecure"}} {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			resetTLSFlags()
+			insecureOption = false
+			buf := new(bytes.Buffer)
+			errBuf := new(bytes.Buffer)
+			RootCmd.SetOut(buf)
+			RootCmd.SetErr(errBuf)
+			defer RootCmd.SetErr(nil)
+			RootCmd.SetArgs(append([]string{"--endpoint", lis.Addr().String(), "--pin-sha256", appendedPin, "--retries", "0", "range", "table", "key"}, args...))
+			RootCmd.Execute()
+
+			assert.Empty(t, strings.TrimSpace(buf.String()))
+			assert.Contains(t, errBuf.String(), "none of the server certificates matches pinned public keys")
+			storage.AssertNotCalled(t, "Range", mock.Anything, mock.Anything)
+		})
+	}
+	insecureOption = false
+
+	t.Run("tls inspect", func(t *testing.T) {
+		resetTLSFlags()
+		buf := new(bytes.Buffer)
+		RootCmd.SetOut(buf)
+		RootCmd.SetArgs([]string{"tls", "inspect", "--endpoint", lis.Addr().String(), "--cert", "test.crt", "--pin-sha256", appendedPin})
+		RootCmd.Execute()
+
+		var results []tlsInspectResult
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &results))
+		require.Len(t, results, 1)
+		assert.Len(t, results[0].Chain, 2)
+		assert.False(t, results[0].Verified)
+		assert.Equal(t, "none of the server certificates matches pinned public keys", results[0].VerificationError)
+	})
+}
+
+// generateCertificate generates self-signed CA certificate, it returns the certificate in DER encoding together with the pin of its public key.
+func generateCertificate(t *testing.T) ([]byte, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "appended"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	hash := spkiHash(cert)
+	return der, base64.StdEncoding.EncodeToString(hash[:])
+}
+
+func Test_Range_PinSHA256(t *testing.T) {
+	resetRangeFlags()
+	defer resetTLSFlags()
+	defer resetEndpointFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("key")}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("key"), Value: []byte("value")}}}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	testCert, err := os.ReadFile("test.crt")
+	require.NoError(t, err)
+	certs, err := parsePEMCertificates(testCert)
+	require.NoError(t, err)
+	hash := spkiHash(certs[0])
+	pin := base64.StdEncoding.EncodeToString(hash[:])
+
+	tests := []struct {
+		name    string
+		pin     string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "matching pin",
+			pin:  pin,
+			want: `[{"key":"key","value":"value"}]`,
+		},
+		{
+			name:    "mismatching pin",
+			pin:     "sha256//47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+			wantErr: "none of the server certificates matches pinned public keys",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTLSFlags()
+			buf := new(bytes.Buffer)
+			errBuf := new(bytes.Buffer)
+			RootCmd.SetOut(buf)
+			RootCmd.SetErr(errBuf)
+			defer RootCmd.SetErr(nil)
+			RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "--pin-sha256", tt.pin, "--retries", "0", "range", "table", "key"})
+			RootCmd.Execute()
+
+			assert.Equal(t, tt.want, strings.TrimSpace(buf.String()))
+			assert.Contains(t, errBuf.String(), tt.wantErr)
+		})
+	}
+}
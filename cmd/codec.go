@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/spf13/cobra"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+var (
+	noCodecName       = "none"
+	noCodec           = codecType(noCodecName)
+	jsonCodecName     = "json"
+	protobufCodecName = "protobuf"
+	msgpackCodecName  = "msgpack"
+	cborCodecName     = "cbor"
+)
+
+// codecType is a serialization format of values, that can be converted from and into JSON.
+type codecType string
+
+func (c *codecType) String() string {
+	return string(*c)
+}
+
+func (c *codecType) Set(v string) error {
+	switch v {
+	case jsonCodecName, protobufCodecName, msgpackCodecName, cborCodecName, noCodecName:
+		*c = codecType(v)
+		return nil
+	default:
+		return errors.New(`must be one of "json", "protobuf", "msgpack", "cbor" or "none"`)
+	}
+}
+
+func (c *codecType) Type() string {
+	return "codecType"
+}
+
+func codecTypeCompletion(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	return []string{
+		"json\tJSON document",
+		"protobuf\tprotobuf message described by --proto-descriptor and --message flags",
+		"msgpack\tMessagePack document",
+		"cbor\tCBOR document",
+		"none\tno conversion",
+	}, cobra.ShellCompDirectiveDefault
+}
+
+// valueDecoder converts value into JSON document.
+type valueDecoder func(data []byte) (json.RawMessage, error)
+
+// newValueDecoder creates decoder for the given codec, protobuf codec requires descriptor set file and message name.
+// For "none" codec nil decoder is returned.
+func newValueDecoder(codec codecType, descriptorFile, message string) (valueDecoder, error) {
+	switch codec.String() {
+	case jsonCodecName:
+		return decodeJSON, nil
+	case msgpackCodecName:
+		return decodeMsgpack, nil
+	case cborCodecName:
+		return decodeCBOR, nil
+	case protobufCodecName:
+		desc, err := loadMessageDescriptor(descriptorFile, message)
+		if err != nil {
+			return nil, err
+		}
+		return func(data []byte) (json.RawMessage, error) {
+			msg := dynamicpb.NewMessage(desc)
+			if err := proto.Unmarshal(data, msg); err != nil {
+				return nil, err
+			}
+			return protojson.Marshal(msg)
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// loadMessageDescriptor finds descriptor of the message with the given full name in the FileDescriptorSet file,
+// as produced by protoc --descriptor_set_out.
+func loadMessageDescriptor(descriptorFile, message string) (protoreflect.MessageDescriptor, error) {
+	if len(descriptorFile) == 0 || len(message) == 0 {
+		return nil, errors.New("protobuf codec requires --proto-descriptor and --message flags")
+	}
+	data, err := os.ReadFile(descriptorFile)
+	if err != nil {
+		return nil, err
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("invalid descriptor set: %w", err)
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor set: %w", err)
+	}
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(message))
+	if err != nil {
+		return nil, fmt.Errorf("message %s not found: %w", message, err)
+	}
+	msgDesc, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message", message)
+	}
+	return msgDesc, nil
+}
+
+func decodeJSON(data []byte) (json.RawMessage, error) {
+	if !json.Valid(data) {
+		return nil, errors.New("invalid JSON")
+	}
+	return data, nil
+}
+
+func decodeMsgpack(data []byte) (json.RawMessage, error) {
+	var v any
+	if err := msgpack.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonCompatible(v))
+}
+
+func decodeCBOR(data []byte) (json.RawMessage, error) {
+	var v any
+	if err := cbor.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonCompatible(v))
+}
+
+// valueEncoder converts JSON document into value.
+type valueEncoder func(data []byte) ([]byte, error)
+
+// newValueEncoder creates encoder for the given codec, protobuf codec requires descriptor set file and message name.
+// For "none" codec nil encoder is returned.
+func newValueEncoder(codec codecType, descriptorFile, message string) (valueEncoder, error) {
+	switch codec.String() {
+	case jsonCodecName:
+		return encodeJSON, nil
+	case msgpackCodecName:
+		return encodeMsgpack, nil
+	case cborCodecName:
+		return encodeCBOR, nil
+	case protobufCodecName:
+		desc, err := loadMessageDescriptor(descriptorFile, message)
+		if err != nil {
+			return nil, err
+		}
+		return func(data []byte) ([]byte, error) {
+			msg := dynamicpb.NewMessage(desc)
+			if err := protojson.Unmarshal(data, msg); err != nil {
+				return nil, err
+			}
+			return proto.Marshal(msg)
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func encodeJSON(data []byte) ([]byte, error) {
+	if !json.Valid(data) {
+		return nil, errors.New("invalid JSON")
+	}
+	return data, nil
+}
+
+func encodeMsgpack(data []byte) ([]byte, error) {
+	v, err := unmarshalJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return msgpack.Marshal(v)
+}
+
+func encodeCBOR(data []byte) ([]byte, error) {
+	v, err := unmarshalJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return cbor.Marshal(v)
+}
+
+// unmarshalJSON unmarshals JSON document, keeping integer numbers as integers instead of floats.
+func unmarshalJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("invalid JSON: unexpected data after JSON document")
+	}
+	return fromJSONNumbers(v), nil
+}
+
+func fromJSONNumbers(v any) any {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+	case map[string]any:
+		for k, val := range t {
+			t[k] = fromJSONNumbers(val)
+		}
+		return t
+	case []any:
+		for i, val := range t {
+			t[i] = fromJSONNumbers(val)
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// jsonCompatible converts maps with non-string keys, as produced by MessagePack and CBOR decoders, into maps with string keys.
+func jsonCompatible(v any) any {
+	switch t := v.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(t))
+		for k, val := range t {
+			m[fmt.Sprint(k)] = jsonCompatible(val)
+		}
+		return m
+	case map[string]any:
+		for k, val := range t {
+			t[k] = jsonCompatible(val)
+		}
+		return t
+	case []any:
+		for i, val := range t {
+			t[i] = jsonCompatible(val)
+		}
+		return t
+	default:
+		return v
+	}
+}
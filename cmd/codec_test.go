@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/jamf/regatta/regattapb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func Test_newValueDecoder(t *testing.T) {
+	descriptorFile := writeDescriptorSet(t)
+	protoValue, err := proto.Marshal(&regattapb.KeyValue{Key: []byte("key"), ModRevision: 5})
+	require.NoError(t, err)
+	msgpackValue, err := msgpack.Marshal(map[string]any{"field": 1})
+	require.NoError(t, err)
+	cborValue, err := cbor.Marshal(map[any]any{1: "value"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		codec   codecType
+		message string
+		value   []byte
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "json",
+			codec: codecType(jsonCodecName),
+			value: []byte(`{"field": 1}`),
+			want:  `{"field": 1}`,
+		},
+		{
+			name:    "invalid json",
+			codec:   codecType(jsonCodecName),
+			value:   []byte(`{"field"`),
+			wantErr: true,
+		},
+		{
+			name:    "protobuf",
+			codec:   codecType(protobufCodecName),
+			message: "mvcc.v1.KeyValue",
+			value:   protoValue,
+			want:    `{"key":"a2V5","modRevision":"5"}`,
+		},
+		{
+			name:  "msgpack",
+			codec: codecType(msgpackCodecName),
+			value: msgpackValue,
+			want:  `{"field":1}`,
+		},
+		{
+			name:  "cbor",
+			codec: codecType(cborCodecName),
+			value: cborValue,
+			want:  `{"1":"value"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder, err := newValueDecoder(tt.codec, descriptorFile, tt.message)
+			require.NoError(t, err)
+
+			decoded, err := decoder(tt.value)
+
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(decoded))
+		})
+	}
+}
+
+func Test_newValueEncoder(t *testing.T) {
+	descriptorFile := writeDescriptorSet(t)
+	protoValue, err := proto.Marshal(&regattapb.KeyValue{Key: []byte("key")})
+	require.NoError(t, err)
+	msgpackValue, err := msgpack.Marshal(map[string]any{"field": int64(1)})
+	require.NoError(t, err)
+	cborValue, err := cbor.Marshal(map[string]any{"field": []any{int64(1), 1.5}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		codec   codecType
+		message string
+		value   string
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name:  "json",
+			codec: codecType(jsonCodecName),
+			value: `{"field": 1}`,
+			want:  []byte(`{"field": 1}`),
+		},
+		{
+			name:    "protobuf",
+			codec:   codecType(protobufCodecName),
+			message: "mvcc.v1.KeyValue",
+			value:   `{"key":"a2V5"}`,
+			want:    protoValue,
+		},
+		{
+			name:    "invalid protobuf",
+			codec:   codecType(protobufCodecName),
+			message: "mvcc.v1.KeyValue",
+			value:   `{"unknown": 1}`,
+			wantErr: true,
+		},
+		{
+			name:  "msgpack",
+			codec: codecType(msgpackCodecName),
+			value: `{"field": 1}`,
+			want:  msgpackValue,
+		},
+		{
+			name:  "cbor",
+			codec: codecType(cborCodecName),
+			value: `{"field": [1, 1.5]}`,
+			want:  cborValue,
+		},
+		{
+			name:    "invalid json",
+			codec:   codecType(msgpackCodecName),
+			value:   `{"field": 1} {}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoder, err := newValueEncoder(tt.codec, descriptorFile, tt.message)
+			require.NoError(t, err)
+
+			encoded, err := encoder([]byte(tt.value))
+
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, encoded)
+		})
+	}
+}
+
+func Test_newValueDecoder_UnknownMessage(t *testing.T) {
+	_, err := newValueDecoder(codecType(protobufCodecName), writeDescriptorSet(t), "mvcc.v1.Unknown")
+
+	require.Error(t, err)
+}
+
+func writeDescriptorSet(t *testing.T) string {
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(regattapb.File_mvcc_proto)}}
+	data, err := proto.Marshal(set)
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "descriptor.pb")
+	require.NoError(t, os.WriteFile(file, data, 0o600))
+	return file
+}
@@ -1,19 +1,45 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"io"
+	"strings"
+	"sync"
 
 	"github.com/jamf/regatta/regattaserver/encoding/gzip"
 	"github.com/jamf/regatta/regattaserver/encoding/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	zstdCompressName = "zstd"
+	lz4CompressName  = "lz4"
+	autoCompressName = "auto"
 )
 
 var (
 	noCompressName = "none"
 	noCompress     = compressType(noCompressName)
 	gzipCompress   = compressType(gzip.Name)
+	autoCompress   = compressType(autoCompressName)
+
+	// autoCompressPreference is an order in which compressors are tried, when compression is negotiated with Regatta.
+	autoCompressPreference = []string{zstdCompressName, gzip.Name, snappy.Name}
 )
 
+func init() {
+	encoding.RegisterCompressor(zstdCompressor{})
+	encoding.RegisterCompressor(lz4Compressor{})
+}
+
 type compressType string
 
 func (c *compressType) String() string {
@@ -22,11 +48,11 @@ func (c *compressType) String() string {
 
 func (c *compressType) Set(v string) error {
 	switch v {
-	case gzip.Name, snappy.Name, noCompressName:
+	case gzip.Name, snappy.Name, zstdCompressName, lz4CompressName, autoCompressName, noCompressName:
 		*c = compressType(v)
 		return nil
 	default:
-		return errors.New(`must be one of "gzip", "snappy" or "none"`)
+		return errors.New(`must be one of "gzip", "snappy", "zstd", "lz4", "auto" or "none"`)
 	}
 }
 
@@ -36,8 +62,136 @@ func (c *compressType) Type() string {
 
 func compressTypeCompletion(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
 	return []string{
-		"gzip\thelp text for gzip",
-		"snappy\thelp text for snappy",
-		"none\thelp text for none",
+		"gzip\tgzip compression, supported by all Regatta versions",
+		"snappy\tsnappy compression, fast with lower compression ratio",
+		"zstd\tzstd compression, best compression ratio, requires Regatta support",
+		"lz4\tlz4 compression, fastest, requires Regatta support",
+		"auto\tuse the best compression supported by Regatta",
+		"none\tno compression",
 	}, cobra.ShellCompDirectiveDefault
 }
+
+// compressCallOptions returns call options applying the compression.
+func compressCallOptions(c compressType) []grpc.CallOption {
+	switch c {
+	case noCompress:
+		return nil
+	case autoCompress:
+		return []grpc.CallOption{autoCompressCallOption{}}
+	default:
+		return []grpc.CallOption{grpc.UseCompressor(c.String())}
+	}
+}
+
+// autoCompressCallOption marks calls, for which the compression should be negotiated with Regatta.
+type autoCompressCallOption struct {
+	grpc.EmptyCallOption
+}
+
+// compressor applies the compression to all calls made using the connection, unless the call selects its own compression.
+// With "auto" compression, compressors are tried in order of preference, until Regatta accepts the request.
+// The negotiated compressor is then used for all subsequent calls, it is negotiated only once the call is handled by Regatta,
+// because calls failed before reaching Regatta do not tell whether Regatta supports the compressor.
+type compressor struct {
+	compress compressType
+
+	mu         sync.Mutex
+	negotiated string
+}
+
+func (c *compressor) intercept(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	compress := c.compress
+	for _, opt := range opts {
+		switch opt.(type) {
+		case grpc.CompressorCallOption:
+			return invoker(ctx, method, req, reply, cc, opts...)
+		case autoCompressCallOption:
+			compress = autoCompress
+		}
+	}
+	if compress != autoCompress {
+		return invoker(ctx, method, req, reply, cc, append(opts, compressCallOptions(compress)...)...)
+	}
+
+	c.mu.Lock()
+	negotiated := c.negotiated
+	c.mu.Unlock()
+	if len(negotiated) != 0 {
+		return invoker(ctx, method, req, reply, cc, append(opts, compressCallOptions(compressType(negotiated))...)...)
+	}
+
+	for _, name := range autoCompressPreference {
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.UseCompressor(name))...)
+		if isCompressorUnsupported(err) {
+			continue
+		}
+		if isHandled(err) {
+			c.setNegotiated(name)
+		}
+		return err
+	}
+	c.setNegotiated(noCompressName)
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+func (c *compressor) setNegotiated(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.negotiated = name
+}
+
+// isCompressorUnsupported reports whether the error was caused by Regatta not supporting the compressor used for the request.
+func isCompressorUnsupported(err error) bool {
+	st, ok := status.FromError(err)
+	return ok && st.Code() == codes.Unimplemented && strings.Contains(st.Message(), "grpc-encoding")
+}
+
+// isHandled reports whether the call was handled by Regatta, calls failed with Unavailable, DeadlineExceeded or Canceled code
+// might not have reached Regatta at all.
+func isHandled(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled:
+		return false
+	default:
+		return true
+	}
+}
+
+// zstdCompressor implements gRPC compressor using zstd.
+type zstdCompressor struct{}
+
+func (zstdCompressor) Name() string {
+	return zstdCompressName
+}
+
+func (zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+}
+
+func (zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+	data, err := io.ReadAll(dec)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// lz4Compressor implements gRPC compressor using lz4.
+type lz4Compressor struct{}
+
+func (lz4Compressor) Name() string {
+	return lz4CompressName
+}
+
+func (lz4Compressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return lz4.NewWriter(w), nil
+}
+
+func (lz4Compressor) Decompress(r io.Reader) (io.Reader, error) {
+	return lz4.NewReader(r), nil
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jamf/regatta/regattapb"
+	"github.com/jamf/regatta/regattaserver"
+	"github.com/jamf/regatta/regattaserver/encoding/gzip"
+	"github.com/jamf/regatta/regattaserver/encoding/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+func Test_Compress_UnsupportedByRegatta(t *testing.T) {
+	resetRangeFlags()
+	defer resetRangeFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	// gRPC compressors are registered globally, so the server supporting only compressors registered by Regatta is emulated
+	var received []string
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())), grpc.UnaryInterceptor(regattaCompressors(&received)))
+
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("test-key")}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("test-key"), Value: []byte("test-value")}}}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	tests := []struct {
+		name         string
+		compress     string
+		want         string
+		wantErr      string
+		wantReceived []string
+	}{
+		{
+			name:         "auto falls back to supported compression",
+			compress:     "auto",
+			want:         `[{"key":"test-key","value":"test-value"}]`,
+			wantReceived: []string{zstdCompressName, gzip.Name},
+		},
+		{
+			name:         "explicit unsupported compression",
+			compress:     "zstd",
+			wantErr:      `Regatta does not support "zstd" compression selected by --compress flag, use "gzip", "snappy", "auto" or "none" instead.`,
+			wantReceived: []string{zstdCompressName},
+		},
+		{
+			name:         "explicit lz4 compression",
+			compress:     "lz4",
+			wantErr:      `Regatta does not support "lz4" compression selected by --compress flag, use "gzip", "snappy", "auto" or "none" instead.`,
+			wantReceived: []string{lz4CompressName},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetRangeFlags()
+			received = nil
+
+			buf := new(bytes.Buffer)
+			errBuf := new(bytes.Buffer)
+			RootCmd.SetOut(buf)
+			RootCmd.SetErr(errBuf)
+			defer RootCmd.SetErr(nil)
+			RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "range", "--compress", tt.compress, "table", "test-key"})
+			RootCmd.Execute()
+
+			assert.Equal(t, tt.want, strings.TrimSpace(buf.String()))
+			assert.Equal(t, tt.wantErr, strings.TrimSpace(errBuf.String()))
+			assert.Equal(t, tt.wantReceived, received)
+		})
+	}
+}
+
+// regattaCompressors returns server interceptor rejecting requests compressed by compressors not registered by Regatta,
+// the same way as gRPC server without the compressor does, compressors of received requests are recorded.
+func regattaCompressors(received *[]string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		stream, ok := grpc.ServerTransportStreamFromContext(ctx).(interface{ RecvCompress() string })
+		if !ok {
+			return handler(ctx, req)
+		}
+		compress := stream.RecvCompress()
+		*received = append(*received, compress)
+		if compress != gzip.Name && compress != snappy.Name && compress != "" {
+			return nil, status.Errorf(codes.Unimplemented, "grpc: Decompressor is not installed for grpc-encoding %q", compress)
+		}
+		return handler(ctx, req)
+	}
+}
+
+func Test_compressor(t *testing.T) {
+	var used []string
+	invoker := recordingInvoker(&used)
+	compressor := &compressor{compress: gzipCompress}
+
+	err := compressor.intercept(context.Background(), "method", nil, nil, nil, invoker)
+	require.NoError(t, err)
+	err = compressor.intercept(context.Background(), "method", nil, nil, nil, invoker, grpc.UseCompressor(snappy.Name))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{gzip.Name, snappy.Name}, used)
+}
+
+func Test_compressor_Auto(t *testing.T) {
+	var used []string
+	invoker := recordingInvoker(&used)
+	compressor := &compressor{compress: autoCompress}
+
+	err := compressor.intercept(context.Background(), "method", nil, nil, nil, invoker)
+	require.NoError(t, err)
+	err = compressor.intercept(context.Background(), "method", nil, nil, nil, invoker)
+	require.NoError(t, err)
+	err = compressor.intercept(context.Background(), "method", nil, nil, nil, invoker, grpc.UseCompressor(snappy.Name))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{zstdCompressName, gzip.Name, gzip.Name, snappy.Name}, used)
+}
+
+func Test_compressor_Auto_Unavailable(t *testing.T) {
+	var used []string
+	invoker := recordingInvoker(&used)
+	compressor := &compressor{compress: autoCompress}
+
+	// the first attempt does not reach Regatta, so zstd is not negotiated
+	unavailable := func(_ context.Context, _ string, _, _ any, _ *grpc.ClientConn, opts ...grpc.CallOption) error {
+		for _, opt := range opts {
+			if c, ok := opt.(grpc.CompressorCallOption); ok {
+				used = append(used, c.CompressorType)
+			}
+		}
+		return status.Error(codes.Unavailable, "connection refused")
+	}
+	err := compressor.intercept(context.Background(), "method", nil, nil, nil, unavailable)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	err = compressor.intercept(context.Background(), "method", nil, nil, nil, invoker)
+	require.NoError(t, err)
+	err = compressor.intercept(context.Background(), "method", nil, nil, nil, invoker)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{zstdCompressName, zstdCompressName, gzip.Name, gzip.Name}, used)
+}
+
+// recordingInvoker records compressors used for calls and rejects zstd compressed calls.
+func recordingInvoker(used *[]string) grpc.UnaryInvoker {
+	return func(_ context.Context, _ string, _, _ any, _ *grpc.ClientConn, opts ...grpc.CallOption) error {
+		name := noCompressName
+		for _, opt := range opts {
+			if c, ok := opt.(grpc.CompressorCallOption); ok {
+				name = c.CompressorType
+			}
+		}
+		*used = append(*used, name)
+		if name == zstdCompressName {
+			return status.Errorf(codes.Unimplemented, "grpc: Decompressor is not installed for grpc-encoding %q", name)
+		}
+		return nil
+	}
+}
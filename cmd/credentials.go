@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// parseHeaders parses headers provided in key=value format into gRPC metadata.
+func parseHeaders(headers []string) (metadata.MD, error) {
+	md := metadata.MD{}
+	for _, header := range headers {
+		key, value, ok := strings.Cut(header, "=")
+		key = strings.TrimSpace(key)
+		if !ok || len(key) == 0 {
+			return nil, fmt.Errorf("header %q is not in key=value format", header)
+		}
+		md.Append(key, value)
+	}
+	return md, nil
+}
+
+// headersInterceptor adds the headers into metadata of each call.
+type headersInterceptor struct {
+	md metadata.MD
+}
+
+func (h *headersInterceptor) intercept(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	outgoing, _ := metadata.FromOutgoingContext(ctx)
+	return invoker(metadata.NewOutgoingContext(ctx, metadata.Join(outgoing, h.md)), method, req, reply, cc, opts...)
+}
+
+// tokenCredentials sends bearer token with each call, when the token is provided in a file,
+// the file is read for each call, so that the refreshed token is used.
+type tokenCredentials struct {
+	token string
+	file  string
+}
+
+func (t *tokenCredentials) GetRequestMetadata(_ context.Context, _ ...string) (map[string]string, error) {
+	token := t.token
+	if len(t.file) != 0 {
+		content, err := os.ReadFile(t.file)
+		if err != nil {
+			return nil, status.Errorf(codes.Unauthenticated, "unable to read token: %v", err)
+		}
+		token = strings.TrimSpace(string(content))
+	}
+	if len(token) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "token is empty")
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+func (t *tokenCredentials) RequireTransportSecurity() bool {
+	return true
+}
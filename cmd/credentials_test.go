@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jamf/regatta/regattapb"
+	"github.com/jamf/regatta/regattaserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+)
+
+func Test_Range_Credentials(t *testing.T) {
+	resetRangeFlags()
+	defer resetCredentialsFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	var received []metadata.MD
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())),
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			md, _ := metadata.FromIncomingContext(ctx)
+			received = append(received, md)
+			return handler(ctx, req)
+		}))
+
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("key")}).
+		Return(&regattapb.RangeResponse{}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	for _, token := range []string{"first-token", "refreshed-token"} {
+		resetCredentialsFlags()
+		require.NoError(t, os.WriteFile(tokenFile, []byte(token+"\n"), 0o600))
+
+		buf := new(bytes.Buffer)
+		RootCmd.SetOut(buf)
+		RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "--token-file", tokenFile,
+			"--header", "x-tenant=123", "--header", "x-tenant=456", "--header", "x-empty=", "range", "table", "key"})
+		RootCmd.Execute()
+
+		assert.Equal(t, `[]`, strings.TrimSpace(buf.String()))
+	}
+
+	require.Len(t, received, 2)
+	assert.Equal(t, []string{"Bearer first-token"}, received[0].Get("authorization"))
+	assert.Equal(t, []string{"Bearer refreshed-token"}, received[1].Get("authorization"))
+	assert.Equal(t, []string{"123", "456"}, received[1].Get("x-tenant"))
+	assert.Equal(t, []string{""}, received[1].Get("x-empty"))
+}
+
+func Test_parseHeaders(t *testing.T) {
+	md, err := parseHeaders([]string{"X-Tenant=123", "x-filter=a=b"})
+	require.NoError(t, err)
+	assert.Equal(t, metadata.MD{"x-tenant": {"123"}, "x-filter": {"a=b"}}, md)
+
+	_, err = parseHeaders([]string{"x-tenant"})
+	require.Error(t, err)
+	_, err = parseHeaders([]string{"=value"})
+	require.Error(t, err)
+}
+
+func resetCredentialsFlags() {
+	headersOption = nil
+	tokenOption = ""
+	tokenFileOption = ""
+}
@@ -1,7 +1,11 @@
 package cmd
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"os"
 	"strings"
 	"time"
 
@@ -9,6 +13,22 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	deleteBinary      bool
+	deleteShowDeleted bool
+	deleteBackupTo    string
+	deleteYes         bool
+	deleteDryRun      bool
+)
+
+func init() {
+	Delete.Flags().BoolVar(&deleteBinary, "binary", false, "avoid decoding deleted keys and values into UTF-8 strings, but rather encode them as Base64 strings")
+	Delete.Flags().BoolVar(&deleteShowDeleted, "show-deleted", false, "print deleted items")
+	Delete.Flags().StringVar(&deleteBackupTo, "backup-to", "", "write items, that are going to be deleted, into the given file before deleting them")
+	Delete.Flags().BoolVarP(&deleteYes, "yes", "y", false, "do not ask for confirmation before deleting items by prefix")
+	Delete.Flags().BoolVar(&deleteDryRun, "dry-run", false, "only print items, that would be deleted, without deleting them")
+}
+
 // Delete is a subcommand used for deleting records in a table.
 var Delete = cobra.Command{
 	Use:   "delete <table> <key>",
@@ -16,54 +36,169 @@ var Delete = cobra.Command{
 	Long: "Deletes data from Regatta store using DeleteRange query as defined in API (https://engineering.jamf.com/regatta/api/#deleterange).\n" +
 		"You can delete single item in Regatta by providing item's key.\n" +
 		"Or you can delete items with given prefix, by providing the given prefix and adding the asterisk (*) to the prefix.\n" +
-		"When key or prefix is provided, it needs to be valid UTF-8 string.",
+		"When key or prefix is provided, it needs to be valid UTF-8 string, unless different encoding is selected using --key-encoding flag.\n" +
+		"Number of deleted items is printed as JSON object with \"deleted\" field. When --show-deleted flag is provided, " +
+		"deleted items are included in \"items\" field in the same format as items retrieved by range command.\n" +
+		"When --backup-to flag is provided, items are retrieved and written into the given file as JSON array before deleting them, " +
+		"keys and values in the backup are always encoded as Base64 strings.\n" +
+		"Before deleting items by prefix, the number of affected items is shown and confirmation is requested, use --yes to skip the confirmation. " +
+		"Use --dry-run to only print the items, that would be deleted.\n" +
+		"Deleting all items in a table is refused for protected endpoints. Endpoints are protected when they match one of the patterns " +
+		"listed one per line in the file protected-endpoints in regatta-client configuration directory (e.g. ~/.config/regatta-client/protected-endpoints) " +
+		"or in comma-separated REGATTA_PROTECTED_ENDPOINTS environment variable, patterns use shell syntax, e.g. '*.prod.example.com:443'.",
 	Example: "regatta-client delete table key\n" +
-		"regatta-client delete table 'prefix*'",
+		"regatta-client delete table 'prefix*'\n" +
+		"regatta-client delete --show-deleted --backup-to backup.json table 'prefix*'\n" +
+		"regatta-client delete --dry-run table 'prefix*'\n" +
+		"regatta-client delete --yes table 'prefix*'",
 	Args: cobra.MatchAll(cobra.ExactArgs(2)),
 	Run: func(cmd *cobra.Command, args []string) {
 		client, err := createClient()
 		if err != nil {
-			cmd.PrintErrln("There was an error, while establishing connection to Regatta.", err)
+			printError(cmd, "There was an error, while establishing connection to Regatta.", err)
 			return
 		}
 
 		timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
-		req := createDeleteRangeRequest(args)
+		req, err := createDeleteRangeRequest(args)
+		if err != nil {
+			printError(cmd, "There was an error while decoding parameters.", err)
+			return
+		}
+
+		if deleteDryRun {
+			results, err := scanDeleteRange(timeoutCtx, client, req, deleteBinary)
+			if err != nil {
+				handleRegattaError(cmd, err)
+				return
+			}
+			marshal, _ := json.Marshal(results)
+			cmd.Println(string(marshal))
+			return
+		}
+
+		if isDeleteAll(req) {
+			endpoints, err := parseEndpoints(endpointOption)
+			if err != nil {
+				printError(cmd, "There was an error while decoding parameters.", err)
+				return
+			}
+			protected, err := isProtected(endpoints)
+			if err != nil {
+				printError(cmd, "There was an error while loading protected endpoints.", err)
+				return
+			}
+			if protected {
+				printError(cmd, "Deleting all items in a table is refused, because the endpoint is protected.")
+				return
+			}
+		}
+
+		if len(req.RangeEnd) != 0 && !deleteYes {
+			countReq := createDeleteRangeScanRequest(req)
+			countReq.CountOnly = true
+			response, err := client.Range(timeoutCtx, countReq)
+			if err != nil {
+				handleRegattaError(cmd, err)
+				return
+			}
+			if !confirmDelete(cmd, string(req.Table), response.Count) {
+				printError(cmd, "Delete was aborted.")
+				return
+			}
+		}
+
+		if len(deleteBackupTo) != 0 {
+			if err := backupDeleteRange(timeoutCtx, client, req); err != nil {
+				printError(cmd, "There was an error, while creating backup of deleted items.", err)
+				return
+			}
+		}
 
-		_, err = client.DeleteRange(timeoutCtx, req)
+		response, err := client.DeleteRange(timeoutCtx, req)
 		if err != nil {
 			handleRegattaError(cmd, err)
+			return
 		}
-	},
-}
 
-func createDeleteRangeRequest(args []string) *regattapb.DeleteRangeRequest {
-	table := args[0]
-	key := args[1]
-	if strings.HasSuffix(key, "*") {
-		key = strings.TrimSuffix(key, "*")
-		if len(key) == 0 {
-			// delete all
-			return &regattapb.DeleteRangeRequest{
-				Table:    []byte(table),
-				Key:      []byte{0},
-				RangeEnd: []byte{0},
-				PrevKv:   true,
+		result := deleteCommandResult{Deleted: response.Deleted}
+		if deleteShowDeleted {
+			result.Items = make([]rangeCommandResult, 0)
+			for _, kv := range response.PrevKvs {
+				result.Items = append(result.Items, newRangeCommandResult(kv, deleteBinary))
 			}
 		}
-		// delete by prefix
-		return &regattapb.DeleteRangeRequest{
-			Table:    []byte(table),
-			Key:      []byte(key),
-			RangeEnd: []byte(findNextString(key)),
-			PrevKv:   true,
-		}
+		marshal, _ := json.Marshal(result)
+		cmd.Println(string(marshal))
+	},
+}
+
+type deleteCommandResult struct {
+	Deleted int64                `json:"deleted"`
+	Items   []rangeCommandResult `json:"items,omitempty"`
+}
+
+func createDeleteRangeRequest(args []string) (*regattapb.DeleteRangeRequest, error) {
+	// delete single, by prefix or all
+	key, rangeEnd, err := parseKeyRange(args[1])
+	if err != nil {
+		return nil, err
 	}
-	// delete single
 	return &regattapb.DeleteRangeRequest{
-		Table:  []byte(table),
-		Key:    []byte(key),
-		PrevKv: true,
+		Table:    []byte(args[0]),
+		Key:      key,
+		RangeEnd: rangeEnd,
+		PrevKv:   true,
+		Count:    true,
+	}, nil
+}
+
+// createDeleteRangeScanRequest creates range request covering the same items as the given delete range request.
+func createDeleteRangeScanRequest(req *regattapb.DeleteRangeRequest) *regattapb.RangeRequest {
+	return &regattapb.RangeRequest{
+		Table:    req.Table,
+		Key:      req.Key,
+		RangeEnd: req.RangeEnd,
+	}
+}
+
+func isDeleteAll(req *regattapb.DeleteRangeRequest) bool {
+	return bytes.Equal(req.Key, zero) && bytes.Equal(req.RangeEnd, zero)
+}
+
+func confirmDelete(cmd *cobra.Command, table string, count int64) bool {
+	cmd.PrintErrf("%d item(s) in table '%s' are going to be deleted, do you want to continue? [y/N] ", count, table)
+	answer, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+func scanDeleteRange(ctx context.Context, client regattapb.KVClient, req *regattapb.DeleteRangeRequest, binary bool) ([]rangeCommandResult, error) {
+	kvs, err := rangeAll(ctx, client, createDeleteRangeScanRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	results := make([]rangeCommandResult, 0, len(kvs))
+	for _, kv := range kvs {
+		results = append(results, newRangeCommandResult(kv, binary))
+	}
+	return results, nil
+}
+
+func backupDeleteRange(ctx context.Context, client regattapb.KVClient, req *regattapb.DeleteRangeRequest) error {
+	results, err := scanDeleteRange(ctx, client, req, true)
+	if err != nil {
+		return err
+	}
+
+	marshal, err := json.Marshal(results)
+	if err != nil {
+		return err
 	}
+	return os.WriteFile(deleteBackupTo, marshal, 0o600)
 }
@@ -3,10 +3,14 @@ package cmd
 import (
 	"bytes"
 	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/jamf/regatta/regattapb"
 	"github.com/jamf/regatta/regattaserver"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
@@ -14,12 +18,14 @@ import (
 )
 
 func Test_Delete(t *testing.T) {
+	resetDeleteFlags()
+
 	lis, err := net.Listen("tcp", "localhost:0")
 	require.NoError(t, err)
 	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
 
 	storage := new(mockKVService)
-	storage.On("Delete", mock.Anything, mock.Anything).Return(&regattapb.DeleteRangeResponse{}, nil)
+	storage.On("Delete", mock.Anything, mock.Anything).Return(&regattapb.DeleteRangeResponse{Deleted: 1}, nil)
 
 	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
 	go s.Serve(lis)
@@ -31,4 +37,273 @@ func Test_Delete(t *testing.T) {
 	RootCmd.Execute()
 
 	storage.AssertExpectations(t)
+	assert.Equal(t, `{"deleted":1}`, strings.TrimSpace(buf.String()))
+}
+
+func Test_Delete_Prefix_Hex(t *testing.T) {
+	resetDeleteFlags()
+	defer func() { keyEncodingOption = utf8Encoding }()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+	storage.On("Delete", mock.Anything, &regattapb.DeleteRangeRequest{Table: []byte("table"), Key: []byte{0x41, 0xff}, RangeEnd: []byte("B"), PrevKv: true, Count: true}).
+		Return(&regattapb.DeleteRangeResponse{Deleted: 1}, nil)
+	storage.On("Delete", mock.Anything, &regattapb.DeleteRangeRequest{Table: []byte("table"), Key: []byte{0xff, 0xff}, RangeEnd: zero, PrevKv: true, Count: true}).
+		Return(&regattapb.DeleteRangeResponse{Deleted: 2}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	tests := []struct {
+		name   string
+		prefix string
+		want   string
+	}{
+		{
+			name:   "prefix ending with 0xff",
+			prefix: "41ff*",
+			want:   `{"deleted":1}`,
+		},
+		{
+			name:   "prefix consisting of 0xff",
+			prefix: "ffff*",
+			want:   `{"deleted":2}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDeleteFlags()
+
+			buf := new(bytes.Buffer)
+			RootCmd.SetOut(buf)
+			RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "--key-encoding", "hex", "delete", "--yes", "table", tt.prefix})
+			RootCmd.Execute()
+
+			assert.Equal(t, tt.want, strings.TrimSpace(buf.String()))
+		})
+	}
+	storage.AssertExpectations(t)
+}
+
+func Test_Delete_ShowDeleted(t *testing.T) {
+	resetDeleteFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+	storage.On("Delete", mock.Anything, &regattapb.DeleteRangeRequest{Table: []byte("table"), Key: []byte("key"), RangeEnd: []byte("kez"), PrevKv: true, Count: true}).
+		Return(&regattapb.DeleteRangeResponse{Deleted: 2, PrevKvs: []*regattapb.KeyValue{
+			{Key: []byte("key1"), Value: []byte("value1")},
+			{Key: []byte("key2"), Value: []byte("value2")},
+		}}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	buf := new(bytes.Buffer)
+	RootCmd.SetOut(buf)
+	RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "delete", "--yes", "--show-deleted", "table", "key*"})
+	RootCmd.Execute()
+
+	storage.AssertExpectations(t)
+	assert.Equal(t, `{"deleted":2,"items":[{"key":"key1","value":"value1"},{"key":"key2","value":"value2"}]}`, strings.TrimSpace(buf.String()))
+}
+
+func Test_Delete_BackupTo(t *testing.T) {
+	resetDeleteFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("key"), RangeEnd: []byte("kez")}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("key1"), Value: []byte("value1")}}}, nil)
+	storage.On("Delete", mock.Anything, mock.Anything).Return(&regattapb.DeleteRangeResponse{Deleted: 1}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	backup := filepath.Join(t.TempDir(), "backup.json")
+
+	buf := new(bytes.Buffer)
+	RootCmd.SetOut(buf)
+	RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "delete", "--yes", "--backup-to", backup, "table", "key*"})
+	RootCmd.Execute()
+
+	storage.AssertExpectations(t)
+	content, err := os.ReadFile(backup)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"key":"a2V5MQ==","value":"dmFsdWUx"}]`, string(content))
+}
+
+func Test_Delete_Confirm(t *testing.T) {
+	resetDeleteFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("key"), RangeEnd: []byte("kez"), CountOnly: true}).
+		Return(&regattapb.RangeResponse{Count: 2}, nil)
+	storage.On("Delete", mock.Anything, mock.Anything).Return(&regattapb.DeleteRangeResponse{Deleted: 2}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	buf := new(bytes.Buffer)
+	errBuf := new(bytes.Buffer)
+	RootCmd.SetOut(buf)
+	RootCmd.SetErr(errBuf)
+	RootCmd.SetIn(strings.NewReader("y\n"))
+	defer RootCmd.SetIn(nil)
+	RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "delete", "table", "key*"})
+	RootCmd.Execute()
+
+	storage.AssertExpectations(t)
+	assert.Contains(t, errBuf.String(), "2 item(s) in table 'table' are going to be deleted")
+	assert.Equal(t, `{"deleted":2}`, strings.TrimSpace(buf.String()))
+}
+
+func Test_Delete_Abort(t *testing.T) {
+	resetDeleteFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, mock.Anything).Return(&regattapb.RangeResponse{Count: 2}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	buf := new(bytes.Buffer)
+	RootCmd.SetOut(buf)
+	RootCmd.SetErr(new(bytes.Buffer))
+	RootCmd.SetIn(strings.NewReader("n\n"))
+	defer RootCmd.SetIn(nil)
+	RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "delete", "table", "key*"})
+	RootCmd.Execute()
+
+	storage.AssertExpectations(t)
+	storage.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	assert.Empty(t, buf.String())
+}
+
+func Test_Delete_DryRun(t *testing.T) {
+	resetDeleteFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: zero, RangeEnd: zero}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("key1"), Value: []byte("value1")}}}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	buf := new(bytes.Buffer)
+	RootCmd.SetOut(buf)
+	RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "delete", "--dry-run", "table", "*"})
+	RootCmd.Execute()
+
+	storage.AssertExpectations(t)
+	storage.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	assert.Equal(t, `[{"key":"key1","value":"value1"}]`, strings.TrimSpace(buf.String()))
+}
+
+func Test_Delete_Protected(t *testing.T) {
+	resetDeleteFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	configDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(configDir, "regatta-client"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "regatta-client", "protected-endpoints"), []byte("# production\n127.0.0.1:*\n"), 0o600))
+
+	tests := []struct {
+		name      string
+		configDir string
+		env       string
+	}{
+		{
+			name:      "protected by configuration file",
+			configDir: configDir,
+		},
+		{
+			name:      "protected by environment variable",
+			configDir: t.TempDir(),
+			env:       "other:443," + lis.Addr().String(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetDeleteFlags()
+			t.Setenv("XDG_CONFIG_HOME", tt.configDir)
+			t.Setenv(protectedEndpointsEnv, tt.env)
+
+			errBuf := new(bytes.Buffer)
+			RootCmd.SetErr(errBuf)
+			defer RootCmd.SetErr(nil)
+			RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "delete", "--yes", "table", "*"})
+			RootCmd.Execute()
+
+			storage.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+			assert.Equal(t, "Deleting all items in a table is refused, because the endpoint is protected.", strings.TrimSpace(errBuf.String()))
+		})
+	}
+}
+
+func Test_isProtected(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv(protectedEndpointsEnv, "*.prod.example.com:443, unix:///var/run/prod/*")
+
+	tests := []struct {
+		endpoints []string
+		want      bool
+	}{
+		{endpoints: []string{"regatta.prod.example.com:443"}, want: true},
+		{endpoints: []string{"regatta.dev.example.com:443", "regatta.prod.example.com:443"}, want: true},
+		{endpoints: []string{"unix:///var/run/prod/regatta.sock"}, want: true},
+		{endpoints: []string{"regatta.dev.example.com:443"}, want: false},
+		{endpoints: []string{"regatta.prod.example.com:8443"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.endpoints, ","), func(t *testing.T) {
+			protected, err := isProtected(tt.endpoints)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, protected)
+		})
+	}
+}
+
+func resetDeleteFlags() {
+	deleteBinary = false
+	deleteShowDeleted = false
+	deleteBackupTo = ""
+	deleteYes = false
+	deleteDryRun = false
 }
@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	utf8Encoding    = encodingType("utf8")
+	base64Encoding  = encodingType("base64")
+	hexEncoding     = encodingType("hex")
+	escapedEncoding = encodingType("escaped")
+	autoEncoding    = outputEncodingType("auto")
+)
+
+type encodingType string
+
+func (e *encodingType) String() string {
+	return string(*e)
+}
+
+func (e *encodingType) Set(v string) error {
+	switch encodingType(v) {
+	case utf8Encoding, base64Encoding, hexEncoding, escapedEncoding:
+		*e = encodingType(v)
+		return nil
+	default:
+		return errors.New(`must be one of "utf8", "base64", "hex" or "escaped"`)
+	}
+}
+
+func (e *encodingType) Type() string {
+	return "encodingType"
+}
+
+// decode decodes the given string into raw bytes using the encoding.
+func (e *encodingType) decode(s string) ([]byte, error) {
+	switch *e {
+	case base64Encoding:
+		return base64.StdEncoding.DecodeString(s)
+	case hexEncoding:
+		return hex.DecodeString(s)
+	case escapedEncoding:
+		return unescape(s)
+	default:
+		return []byte(s), nil
+	}
+}
+
+// outputEncodingType is an encoding used for printing keys and values,
+// in addition to encodingType values it allows "auto" encoding.
+type outputEncodingType encodingType
+
+func (e *outputEncodingType) String() string {
+	return string(*e)
+}
+
+func (e *outputEncodingType) Set(v string) error {
+	switch encodingType(v) {
+	case utf8Encoding, base64Encoding, hexEncoding, escapedEncoding, encodingType(autoEncoding):
+		*e = outputEncodingType(v)
+		return nil
+	default:
+		return errors.New(`must be one of "utf8", "base64", "hex", "escaped" or "auto"`)
+	}
+}
+
+func (e *outputEncodingType) Type() string {
+	return "outputEncodingType"
+}
+
+// encode encodes the given data into string using the encoding.
+// With "auto" encoding data are encoded as UTF-8 string when they are valid UTF-8, otherwise as Base64 string,
+// the resolved encoding is returned, so it can be shown next to the encoded data. For other encodings empty string is returned instead.
+func (e *outputEncodingType) encode(data []byte) (string, string) {
+	switch encodingType(*e) {
+	case base64Encoding:
+		return base64.StdEncoding.EncodeToString(data), ""
+	case hexEncoding:
+		return hex.EncodeToString(data), ""
+	case escapedEncoding:
+		return escape(data), ""
+	case encodingType(autoEncoding):
+		if utf8.Valid(data) {
+			return string(data), string(utf8Encoding)
+		}
+		return base64.StdEncoding.EncodeToString(data), string(base64Encoding)
+	default:
+		return string(data), ""
+	}
+}
+
+func encodingTypeCompletion(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	return []string{
+		"utf8\tdata as UTF-8 string",
+		"base64\tdata encoded as Base64 string",
+		"hex\tdata encoded as hexadecimal string",
+		"escaped\tdata as string with escape sequences like \\n, \\\\ or \\x00",
+	}, cobra.ShellCompDirectiveDefault
+}
+
+func outputEncodingTypeCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	completions, directive := encodingTypeCompletion(cmd, args, toComplete)
+	return append(completions, "auto\tUTF-8 string when data are valid UTF-8, otherwise Base64 string"), directive
+}
+
+// parseKey decodes the key provided as an argument using the given encoding.
+// A trailing asterisk (*) marks the key as a prefix, with escaped encoding a literal trailing asterisk can be provided as \*.
+func parseKey(arg string, enc encodingType) (key []byte, prefix bool, err error) {
+	if strings.HasSuffix(arg, "*") && !(enc == escapedEncoding && isEscapedSuffix(arg)) {
+		arg = strings.TrimSuffix(arg, "*")
+		prefix = true
+	}
+	key, err = enc.decode(arg)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid %s key: %w", enc, err)
+	}
+	return key, prefix, nil
+}
+
+// parseKeyRange decodes the key provided as an argument into the range of keys, key with a trailing asterisk (*) selects
+// all keys with the given prefix and a sole asterisk selects all keys. For a single key, the range end is nil.
+func parseKeyRange(arg string) (key, rangeEnd []byte, err error) {
+	key, prefix, err := parseKey(arg, keyEncodingOption)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case !prefix:
+		return key, nil, nil
+	case len(key) == 0:
+		return zero, zero, nil
+	default:
+		return key, prefixRangeEnd(key), nil
+	}
+}
+
+// isEscapedSuffix reports whether the last character is preceded by an odd number of backslashes.
+func isEscapedSuffix(s string) bool {
+	backslashes := 0
+	for i := len(s) - 2; i >= 0 && s[i] == '\\'; i-- {
+		backslashes++
+	}
+	return backslashes%2 == 1
+}
+
+func unescape(s string) ([]byte, error) {
+	buf := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			buf = append(buf, s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return nil, errors.New("unterminated escape sequence")
+		}
+		switch s[i] {
+		case '\\':
+			buf = append(buf, '\\')
+		case '*':
+			buf = append(buf, '*')
+		case '0':
+			buf = append(buf, 0)
+		case 'n':
+			buf = append(buf, '\n')
+		case 'r':
+			buf = append(buf, '\r')
+		case 't':
+			buf = append(buf, '\t')
+		case 'x':
+			if i+3 > len(s) {
+				return nil, errors.New(`incomplete \x escape sequence`)
+			}
+			b, err := hex.DecodeString(s[i+1 : i+3])
+			if err != nil {
+				return nil, fmt.Errorf(`invalid \x escape sequence: %w`, err)
+			}
+			buf = append(buf, b[0])
+			i += 2
+		default:
+			return nil, fmt.Errorf(`unknown escape sequence \%c`, s[i])
+		}
+	}
+	return buf, nil
+}
+
+func escape(data []byte) string {
+	var sb strings.Builder
+	for i, b := range data {
+		switch {
+		case b == '\\':
+			sb.WriteString(`\\`)
+		case b == 0:
+			sb.WriteString(`\0`)
+		case b == '\n':
+			sb.WriteString(`\n`)
+		case b == '\r':
+			sb.WriteString(`\r`)
+		case b == '\t':
+			sb.WriteString(`\t`)
+		case b == '*' && i == len(data)-1:
+			// trailing asterisk would be otherwise interpreted as prefix marker, when provided back as a key
+			sb.WriteString(`\*`)
+		case b < 0x20 || b >= 0x7f:
+			fmt.Fprintf(&sb, `\x%02x`, b)
+		default:
+			sb.WriteByte(b)
+		}
+	}
+	return sb.String()
+}
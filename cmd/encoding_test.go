@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		arg        string
+		enc        encodingType
+		wantKey    []byte
+		wantPrefix bool
+		wantErr    bool
+	}{
+		{
+			name:    "utf8 key",
+			arg:     "key",
+			enc:     utf8Encoding,
+			wantKey: []byte("key"),
+		},
+		{
+			name:       "utf8 prefix",
+			arg:        "key*",
+			enc:        utf8Encoding,
+			wantKey:    []byte("key"),
+			wantPrefix: true,
+		},
+		{
+			name:    "base64 key",
+			arg:     "AP8=",
+			enc:     base64Encoding,
+			wantKey: []byte{0x00, 0xff},
+		},
+		{
+			name:       "hex prefix",
+			arg:        "00ff*",
+			enc:        hexEncoding,
+			wantKey:    []byte{0x00, 0xff},
+			wantPrefix: true,
+		},
+		{
+			name:    "hex key with asterisk",
+			arg:     "6b2a",
+			enc:     hexEncoding,
+			wantKey: []byte("k*"),
+		},
+		{
+			name:    "escaped key with literal asterisk",
+			arg:     `key\*`,
+			enc:     escapedEncoding,
+			wantKey: []byte("key*"),
+		},
+		{
+			name:       "escaped prefix",
+			arg:        `key\\\x00*`,
+			enc:        escapedEncoding,
+			wantKey:    []byte("key\\\x00"),
+			wantPrefix: true,
+		},
+		{
+			name:    "invalid hex",
+			arg:     "zz",
+			enc:     hexEncoding,
+			wantErr: true,
+		},
+		{
+			name:    "invalid escape sequence",
+			arg:     `key\x0`,
+			enc:     escapedEncoding,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, prefix, err := parseKey(tt.arg, tt.enc)
+
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantKey, key)
+			assert.Equal(t, tt.wantPrefix, prefix)
+		})
+	}
+}
+
+func Test_parseKeyRange(t *testing.T) {
+	defer func() { keyEncodingOption = utf8Encoding }()
+	keyEncodingOption = hexEncoding
+
+	tests := []struct {
+		name         string
+		arg          string
+		wantKey      []byte
+		wantRangeEnd []byte
+	}{
+		{
+			name:    "key",
+			arg:     "41ff",
+			wantKey: []byte{0x41, 0xff},
+		},
+		{
+			name:         "prefix",
+			arg:          "4142*",
+			wantKey:      []byte("AB"),
+			wantRangeEnd: []byte("AC"),
+		},
+		{
+			name:         "prefix ending with 0xff",
+			arg:          "41ff*",
+			wantKey:      []byte{0x41, 0xff},
+			wantRangeEnd: []byte("B"),
+		},
+		{
+			name:         "prefix ending with multiple 0xff",
+			arg:          "4100ffff*",
+			wantKey:      []byte{0x41, 0x00, 0xff, 0xff},
+			wantRangeEnd: []byte{0x41, 0x01},
+		},
+		{
+			name:         "prefix consisting of 0xff",
+			arg:          "ffff*",
+			wantKey:      []byte{0xff, 0xff},
+			wantRangeEnd: zero,
+		},
+		{
+			name:         "all keys",
+			arg:          "*",
+			wantKey:      zero,
+			wantRangeEnd: zero,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, rangeEnd, err := parseKeyRange(tt.arg)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantKey, key)
+			assert.Equal(t, tt.wantRangeEnd, rangeEnd)
+		})
+	}
+}
+
+func Test_outputEncodingType_encode(t *testing.T) {
+	tests := []struct {
+		name         string
+		data         []byte
+		enc          outputEncodingType
+		wantData     string
+		wantEncoding string
+	}{
+		{
+			name:     "utf8",
+			data:     []byte("data"),
+			enc:      outputEncodingType(utf8Encoding),
+			wantData: "data",
+		},
+		{
+			name:     "base64",
+			data:     []byte{0x00, 0xff},
+			enc:      outputEncodingType(base64Encoding),
+			wantData: "AP8=",
+		},
+		{
+			name:     "hex",
+			data:     []byte{0x00, 0xff},
+			enc:      outputEncodingType(hexEncoding),
+			wantData: "00ff",
+		},
+		{
+			name:     "escaped",
+			data:     []byte("key\\\x00\n\xff*"),
+			enc:      outputEncodingType(escapedEncoding),
+			wantData: `key\\\0\n\xff\*`,
+		},
+		{
+			name:         "auto valid UTF-8",
+			data:         []byte("data"),
+			enc:          autoEncoding,
+			wantData:     "data",
+			wantEncoding: "utf8",
+		},
+		{
+			name:         "auto invalid UTF-8",
+			data:         []byte{0x00, 0xff},
+			enc:          autoEncoding,
+			wantData:     "AP8=",
+			wantEncoding: "base64",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, encoding := tt.enc.encode(tt.data)
+
+			assert.Equal(t, tt.wantData, data)
+			assert.Equal(t, tt.wantEncoding, encoding)
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	pickFirstBalancing  = balancingType("pick-first")
+	roundRobinBalancing = balancingType("round-robin")
+)
+
+type balancingType string
+
+func (b *balancingType) String() string {
+	return string(*b)
+}
+
+func (b *balancingType) Set(v string) error {
+	switch balancingType(v) {
+	case pickFirstBalancing, roundRobinBalancing:
+		*b = balancingType(v)
+		return nil
+	default:
+		return errors.New(`must be one of "pick-first" or "round-robin"`)
+	}
+}
+
+func (b *balancingType) Type() string {
+	return "balancingType"
+}
+
+func balancingTypeCompletion(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	return []string{
+		"pick-first\tsend all requests to the first available endpoint",
+		"round-robin\tspread requests across all available endpoints",
+	}, cobra.ShellCompDirectiveDefault
+}
+
+// serviceConfig returns gRPC service config selecting the load balancing policy.
+func (b balancingType) serviceConfig() string {
+	policy := "pick_first"
+	if b == roundRobinBalancing {
+		policy = "round_robin"
+	}
+	return fmt.Sprintf(`{"loadBalancingConfig":[{%q:{}}]}`, policy)
+}
+
+// parseEndpoints splits comma-separated list of endpoints.
+func parseEndpoints(endpoint string) ([]string, error) {
+	var endpoints []string
+	for _, e := range strings.Split(endpoint, ",") {
+		e = strings.TrimSpace(e)
+		if len(e) == 0 {
+			continue
+		}
+		endpoints = append(endpoints, e)
+	}
+	if len(endpoints) == 0 {
+		return nil, errors.New("no endpoint provided")
+	}
+	return endpoints, nil
+}
+
+// endpointTarget returns gRPC dial target for the endpoint, endpoint without scheme is resolved using DNS,
+// so that all addresses of the name are used.
+func endpointTarget(endpoint string) string {
+	if hasScheme(endpoint) {
+		return endpoint
+	}
+	return "dns:///" + endpoint
+}
+
+// hasScheme reports whether the endpoint is provided as gRPC target with scheme, e.g. unix:///path/to/regatta.sock.
+func hasScheme(endpoint string) bool {
+	return strings.Contains(endpoint, "://") || isUnixEndpoint(endpoint)
+}
+
+// isUnixEndpoint reports whether the endpoint is Unix domain socket,
+// e.g. unix:///path/to/regatta.sock, unix:relative/path/to/regatta.sock or unix-abstract:name.
+func isUnixEndpoint(endpoint string) bool {
+	return strings.HasPrefix(endpoint, "unix:") || strings.HasPrefix(endpoint, "unix-abstract:")
+}
+
+// unixSocketAddress returns address of Unix domain socket passed by gRPC to the context dialer,
+// or false when the address is not Unix domain socket.
+func unixSocketAddress(addr string) (string, bool) {
+	switch {
+	case strings.HasPrefix(addr, "unix://"):
+		return strings.TrimPrefix(addr, "unix://"), true
+	case strings.HasPrefix(addr, "unix:"):
+		return strings.TrimPrefix(addr, "unix:"), true
+	case strings.HasPrefix(addr, "\x00"):
+		// abstract socket
+		return "@" + strings.TrimPrefix(addr, "\x00"), true
+	default:
+		return "", false
+	}
+}
+
+// endpointsConn is a connection to a cluster of Regatta nodes, it holds a connection to each of the endpoints.
+// Idempotent calls failed with Unavailable code are retried on the following endpoints, so that they are served by another member of the cluster.
+// With pick-first balancing, calls are sent to the endpoint which served the last call, with round-robin balancing,
+// each call starts at the next endpoint. When the call fails on all endpoints, it is retried by the retrier with backoff,
+// so that the backoff is not spent on a single unavailable endpoint.
+// When verbose output is set, the failovers are reported into it.
+type endpointsConn struct {
+	conns     []*grpc.ClientConn
+	balancing balancingType
+	retrier   *retrier
+	verbose   io.Writer
+
+	mu   sync.Mutex
+	next int
+}
+
+// first returns index of the endpoint, which should be used for the next call.
+func (e *endpointsConn) first() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	first := e.next
+	if e.balancing == roundRobinBalancing {
+		e.next = (e.next + 1) % len(e.conns)
+	}
+	return first
+}
+
+func (e *endpointsConn) served(i int) {
+	if e.balancing == roundRobinBalancing {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.next = i
+}
+
+func (e *endpointsConn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	call := func() error {
+		return e.failover(ctx, method, args, reply, opts...)
+	}
+	if e.retrier == nil || hasNoRetry(opts) {
+		return call()
+	}
+	return e.retrier.invoke(ctx, args, call)
+}
+
+// failover makes the call on the endpoints one by one, until it does not fail with Unavailable code.
+// Calls, which are not idempotent, are made only on the first endpoint, because they could have been applied before they failed.
+func (e *endpointsConn) failover(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	var err error
+	first := e.first()
+	attempts := len(e.conns)
+	if !isIdempotent(args) {
+		attempts = 1
+	}
+	for attempt := 0; attempt < attempts; attempt++ {
+		i := (first + attempt) % len(e.conns)
+		err = e.conns[i].Invoke(ctx, method, args, reply, opts...)
+		if status.Code(err) != codes.Unavailable || ctx.Err() != nil {
+			e.served(i)
+			return err
+		}
+		if e.verbose != nil && attempt < attempts-1 {
+			fmt.Fprintf(e.verbose, "%s failed on %s with %s, failing over to another endpoint\n",
+				method, e.conns[i].Target(), status.Convert(err).Message())
+		}
+	}
+	return err
+}
+
+func (e *endpointsConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return e.conns[e.first()].NewStream(ctx, desc, method, opts...)
+}
+
+// Close closes connections to all endpoints.
+func (e *endpointsConn) Close() error {
+	var errs []error
+	for _, conn := range e.conns {
+		errs = append(errs, conn.Close())
+	}
+	return errors.Join(errs...)
+}
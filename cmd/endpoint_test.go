@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jamf/regatta/regattapb"
+	"github.com/jamf/regatta/regattaserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+func Test_parseEndpoints(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "single endpoint",
+			endpoint: "localhost:8443",
+			want:     []string{"localhost:8443"},
+		},
+		{
+			name:     "multiple endpoints",
+			endpoint: "regatta-0:8443, regatta-1:8443,,regatta-2:8443",
+			want:     []string{"regatta-0:8443", "regatta-1:8443", "regatta-2:8443"},
+		},
+		{
+			name:     "no endpoint",
+			endpoint: " , ",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			endpoints, err := parseEndpoints(tt.endpoint)
+
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, endpoints)
+		})
+	}
+}
+
+func Test_Range_Failover(t *testing.T) {
+	resetRangeFlags()
+	defer resetEndpointFlags()
+
+	unavailableLis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	unavailable := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+	unavailableKV := &unavailableKVServer{}
+	regattapb.RegisterKVServer(unavailable, unavailableKV)
+	go unavailable.Serve(unavailableLis)
+	defer unavailable.Stop()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("key")}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("key"), Value: []byte("value")}}}, nil)
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	for i := 1; i <= 3; i++ {
+		buf := new(bytes.Buffer)
+		errBuf := new(bytes.Buffer)
+		RootCmd.SetOut(buf)
+		RootCmd.SetErr(errBuf)
+		// the failover happens before the retry backoff, so the long backoff is never waited for
+		RootCmd.SetArgs([]string{"--endpoint", unavailableLis.Addr().String() + "," + lis.Addr().String(), "--cert", "test.crt",
+			"--load-balancing", "round-robin", "--retry-backoff", "1m", "--verbose", "range", "table", "key"})
+		start := time.Now()
+		RootCmd.Execute()
+
+		assert.Less(t, time.Since(start), 10*time.Second)
+		assert.Equal(t, `[{"key":"key","value":"value"}]`, strings.TrimSpace(buf.String()))
+		assert.Contains(t, errBuf.String(), "failing over to another endpoint")
+		assert.Contains(t, errBuf.String(), "< /regatta.v1.KV/Range OK in ")
+		assert.Contains(t, errBuf.String(), ", endpoint "+lis.Addr().String())
+		assert.Equal(t, int32(i), unavailableKV.calls.Load())
+	}
+}
+
+func Test_Txn_NoFailover(t *testing.T) {
+	defer resetEndpointFlags()
+	defer resetTLSFlags()
+
+	unavailableLis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	unavailable := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+	unavailableKV := &unavailableKVServer{}
+	regattapb.RegisterKVServer(unavailable, unavailableKV)
+	go unavailable.Serve(unavailableLis)
+	defer unavailable.Stop()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+	storage := new(mockKVService)
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	certOption = "test.crt"
+	retryBackoff = time.Millisecond
+	conn, err := dialEndpoints(context.Background(), []string{unavailableLis.Addr().String(), lis.Addr().String()})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// transaction modifying data could have been applied by the unavailable node, so it is neither failed over nor retried
+	_, err = regattapb.NewKVClient(conn).Txn(context.Background(), &regattapb.TxnRequest{
+		Table:   []byte("table"),
+		Success: []*regattapb.RequestOp{{Request: &regattapb.RequestOp_RequestPut{RequestPut: &regattapb.RequestOp_Put{Key: []byte("key")}}}},
+	})
+
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, int32(1), unavailableKV.calls.Load())
+	storage.AssertNotCalled(t, "Txn", mock.Anything, mock.Anything)
+}
+
+func Test_Range_EndpointDown(t *testing.T) {
+	resetRangeFlags()
+	defer resetEndpointFlags()
+
+	downLis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	downLis.Close()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("key")}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("key"), Value: []byte("value")}}}, nil)
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	buf := new(bytes.Buffer)
+	RootCmd.SetOut(buf)
+	RootCmd.SetArgs([]string{"--endpoint", downLis.Addr().String() + "," + lis.Addr().String(), "--cert", "test.crt", "range", "table", "key"})
+	RootCmd.Execute()
+
+	assert.Equal(t, `[{"key":"key","value":"value"}]`, strings.TrimSpace(buf.String()))
+}
+
+// unavailableKVServer simulates Regatta node, which is shutting down, it counts received calls.
+type unavailableKVServer struct {
+	regattapb.UnimplementedKVServer
+	calls atomic.Int32
+}
+
+func (s *unavailableKVServer) Range(context.Context, *regattapb.RangeRequest) (*regattapb.RangeResponse, error) {
+	s.calls.Add(1)
+	return nil, status.Error(codes.Unavailable, "node is shutting down")
+}
+
+func (s *unavailableKVServer) Txn(context.Context, *regattapb.TxnRequest) (*regattapb.TxnResponse, error) {
+	s.calls.Add(1)
+	return nil, status.Error(codes.Unavailable, "node is shutting down")
+}
+
+func resetEndpointFlags() {
+	balancingOption = pickFirstBalancing
+	verboseOption = false
+	debugOption = false
+	retriesOption = 3
+	retryBackoff = 100 * time.Millisecond
+	RootCmd.SetErr(nil)
+}
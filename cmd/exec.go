@@ -0,0 +1,266 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	execFile        string
+	execStopOnError bool
+)
+
+func init() {
+	Exec.Flags().StringVarP(&execFile, "file", "f", "", "file containing commands, standard input is used when not provided")
+	Exec.Flags().BoolVar(&execStopOnError, "stop-on-error", false, "stop executing commands after the first failed command")
+}
+
+// Exec is a subcommand used for executing multiple commands over a single connection.
+var Exec = cobra.Command{
+	Use:   "exec",
+	Short: "Execute multiple commands over a single connection",
+	Long: "Executes commands read from the file or standard input over a single connection to Regatta.\n" +
+		"Each line contains a single command with its arguments and flags, as they would be provided to regatta-client, for example \"put table key value\". " +
+		"Arguments can be quoted using single or double quotes and backslash escapes the following character outside of single quotes. " +
+		"Empty lines and lines starting with # are skipped.\n" +
+		"Global flags provided to exec command apply to all commands, the connection related flags provided on the lines are ignored. " +
+		"Commands cannot read standard input, so commands requiring confirmation need to be confirmed by their flags, like delete --yes.\n" +
+		"Result of each command is printed as JSON object on a separate line, with \"line\" field containing the line number, " +
+		"\"command\" field containing the command, \"output\" field containing the output of the command (embedded as JSON when the output is valid JSON) " +
+		"and \"error\" field containing the error. The command is considered failed, when it would exit with non-zero status, " +
+		"messages printed to standard error by succeeded commands are shown in \"stderr\" field. " +
+		"Finally, the summary with numbers of executed, succeeded and failed commands is printed as JSON object.",
+	Example: "regatta-client exec --file ops.txt\n" +
+		"regatta-client exec --stop-on-error < ops.txt",
+	Args: cobra.MatchAll(cobra.NoArgs),
+	Run: func(cmd *cobra.Command, _ []string) {
+		input := cmd.InOrStdin()
+		if len(execFile) != 0 {
+			file, err := os.Open(execFile)
+			if err != nil {
+				printError(cmd, "There was an error, while reading commands.", err)
+				return
+			}
+			defer file.Close()
+			input = file
+		}
+
+		conn, err := dial()
+		if err != nil {
+			printError(cmd, "There was an error, while establishing connection to Regatta.", err)
+			return
+		}
+		sharedConn = conn
+		defer func() {
+			sharedConn = nil
+			conn.Close()
+		}()
+
+		summary, err := executeCommands(cmd, input)
+		if summary.Failed != 0 {
+			exitStatus = 1
+		}
+		if err != nil {
+			printError(cmd, "There was an error, while reading commands.", err)
+		}
+		marshal, _ := json.Marshal(execSummary{Summary: summary})
+		cmd.Println(string(marshal))
+	},
+}
+
+type execResult struct {
+	Line    int    `json:"line"`
+	Command string `json:"command"`
+	Output  any    `json:"output,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Stderr  string `json:"stderr,omitempty"`
+
+	failed bool
+}
+
+type execSummary struct {
+	Summary execSummaryCounts `json:"summary"`
+}
+
+type execSummaryCounts struct {
+	Executed  int `json:"executed"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+func executeCommands(cmd *cobra.Command, input io.Reader) (execSummaryCounts, error) {
+	root := cmd.Root()
+	out, errOut, in := cmd.OutOrStdout(), cmd.ErrOrStderr(), cmd.InOrStdin()
+	silenceUsage, silenceErrors := root.SilenceUsage, root.SilenceErrors
+	snapshot := snapshotFlags(root)
+	defer func() {
+		root.SetOut(out)
+		root.SetErr(errOut)
+		root.SetIn(in)
+		root.SilenceUsage, root.SilenceErrors = silenceUsage, silenceErrors
+		restoreFlags(snapshot)
+	}()
+	root.SilenceUsage = true
+
+	var summary execSummaryCounts
+	scanner := bufio.NewScanner(input)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		result := execResult{Line: line, Command: text}
+		args, err := splitCommandLine(text)
+		switch {
+		case err != nil:
+			result.Error, result.failed = err.Error(), true
+		case len(args) != 0 && args[0] == cmd.Name():
+			result.Error, result.failed = "exec command cannot be nested", true
+		default:
+			result = executeCommandLine(root, snapshot, result, args)
+		}
+
+		summary.Executed++
+		if result.failed {
+			summary.Failed++
+		} else {
+			summary.Succeeded++
+		}
+		marshal, _ := json.Marshal(result)
+		_, _ = io.WriteString(out, string(marshal)+"\n")
+		if result.failed && execStopOnError {
+			break
+		}
+	}
+	return summary, scanner.Err()
+}
+
+// executeCommandLine executes the command given by the arguments, the command fails, when it would exit with non-zero status.
+func executeCommandLine(root *cobra.Command, snapshot flagSnapshot, result execResult, args []string) execResult {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	restoreFlags(snapshot)
+	root.SetOut(stdout)
+	root.SetErr(stderr)
+	root.SetIn(strings.NewReader(""))
+	root.SetArgs(args)
+	result.failed = executeCommand() != 0
+	result.Output = commandOutput(stdout.Bytes())
+	messages := strings.TrimSpace(stderr.String())
+	switch {
+	case !result.failed:
+		result.Stderr = messages
+	case len(messages) != 0:
+		result.Error = messages
+	default:
+		result.Error = "command failed"
+	}
+	return result
+}
+
+// commandOutput returns the output as JSON document if it is valid JSON, otherwise as string.
+func commandOutput(output []byte) any {
+	output = bytes.TrimSpace(output)
+	switch {
+	case len(output) == 0:
+		return nil
+	case json.Valid(output):
+		return json.RawMessage(output)
+	default:
+		return string(output)
+	}
+}
+
+// flagSnapshot holds values of flags, values of slice flags are held as slices, because their string representation cannot be set back.
+type flagSnapshot map[*pflag.Flag]any
+
+// snapshotFlags captures values of flags of the command and all its subcommands.
+func snapshotFlags(root *cobra.Command) flagSnapshot {
+	snapshot := make(flagSnapshot)
+	var visit func(c *cobra.Command)
+	visit = func(c *cobra.Command) {
+		c.Flags().VisitAll(func(f *pflag.Flag) {
+			snapshot[f] = flagValue(f)
+		})
+		c.PersistentFlags().VisitAll(func(f *pflag.Flag) {
+			snapshot[f] = flagValue(f)
+		})
+		for _, sub := range c.Commands() {
+			visit(sub)
+		}
+	}
+	visit(root)
+	return snapshot
+}
+
+func flagValue(f *pflag.Flag) any {
+	if s, ok := f.Value.(pflag.SliceValue); ok {
+		return append([]string(nil), s.GetSlice()...)
+	}
+	return f.Value.String()
+}
+
+func restoreFlags(snapshot flagSnapshot) {
+	for f, value := range snapshot {
+		switch v := value.(type) {
+		case []string:
+			_ = f.Value.(pflag.SliceValue).Replace(append([]string(nil), v...))
+		case string:
+			_ = f.Value.Set(v)
+		}
+		f.Changed = false
+	}
+}
+
+// splitCommandLine splits the line into arguments separated by whitespace, arguments can be quoted using single or double quotes
+// and backslash escapes the following character outside of single quotes.
+func splitCommandLine(line string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape sequence")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
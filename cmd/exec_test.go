@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jamf/regatta/regattapb"
+	"github.com/jamf/regatta/regattaserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+func Test_Exec(t *testing.T) {
+	resetRangeFlags()
+	resetPutFlags()
+	defer func() { execStopOnError = false }()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+	storage.On("Put", mock.Anything, &regattapb.PutRequest{Table: []byte("table"), Key: []byte("key"), Value: []byte("some value")}).
+		Return(&regattapb.PutResponse{}, nil)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("key"), Limit: 1}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("key"), Value: []byte("some value")}}}, nil)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("key")}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("key"), Value: []byte("some value")}}}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	script := "# comment\n" +
+		"put table key 'some value'\n" +
+		"\n" +
+		"range --limit 1 table key\n" +
+		"range table key\n" +
+		"unknown\n" +
+		"range table key\n"
+
+	buf := new(bytes.Buffer)
+	RootCmd.SetOut(buf)
+	RootCmd.SetIn(strings.NewReader(script))
+	defer RootCmd.SetIn(nil)
+	RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "exec", "--stop-on-error"})
+	RootCmd.Execute()
+
+	storage.AssertExpectations(t)
+	assert.Equal(t, `{"line":2,"command":"put table key 'some value'"}
+{"line":4,"command":"range --limit 1 table key","output":[{"key":"key","value":"some value"}]}
+{"line":5,"command":"range table key","output":[{"key":"key","value":"some value"}]}
+{"line":6,"command":"unknown","error":"Error: unknown command \"unknown\" for \"regatta-client\"\nRun 'regatta-client --help' for usage."}
+{"summary":{"executed":4,"succeeded":3,"failed":1}}`, strings.TrimSpace(buf.String()))
+}
+
+func Test_Exec_FailedWithoutError(t *testing.T) {
+	resetRangeFlags()
+	resetPingFlags()
+	defer func() { execStopOnError = false }()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte{0}, RangeEnd: []byte{0}, Limit: 1, KeysOnly: true}).
+		Return((*regattapb.RangeResponse)(nil), errors.New("storage failure"))
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	// ping reports failed round-trips only in its output, so the failure is recognized by its exit status
+	script := "ping -c 1 table\n" +
+		"range table key\n"
+
+	buf := new(bytes.Buffer)
+	RootCmd.SetOut(buf)
+	RootCmd.SetIn(strings.NewReader(script))
+	defer RootCmd.SetIn(nil)
+	RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "exec", "--stop-on-error"})
+	assert.Equal(t, 1, executeCommand())
+
+	storage.AssertExpectations(t)
+	lines := readJSONLines(t, buf)
+	require.Len(t, lines, 2)
+	var result execResult
+	require.NoError(t, json.Unmarshal(lines[0], &result))
+	assert.Equal(t, "ping -c 1 table", result.Command)
+	assert.Equal(t, "command failed", result.Error)
+	assert.Contains(t, result.Output, `"code":"Internal"`)
+	assert.JSONEq(t, `{"summary":{"executed":1,"succeeded":0,"failed":1}}`, string(lines[1]))
+}
+
+func Test_splitCommandLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "plain arguments",
+			line: "put  table\tkey value",
+			want: []string{"put", "table", "key", "value"},
+		},
+		{
+			name: "quoted arguments",
+			line: `put table 'prefix*' "some \"value\"" ''`,
+			want: []string{"put", "table", "prefix*", `some "value"`, ""},
+		},
+		{
+			name: "escaped arguments",
+			line: `put table key\ with\ spaces '\x00'`,
+			want: []string{"put", "table", "key with spaces", `\x00`},
+		},
+		{
+			name:    "unterminated quote",
+			line:    `put table 'key`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := splitCommandLine(tt.line)
+
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, args)
+		})
+	}
+}
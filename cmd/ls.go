@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/jamf/regatta/regattapb"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lsDelimiter string
+	lsLimit     int
+	lsPageSize  int64
+)
+
+func init() {
+	Ls.Flags().StringVar(&lsDelimiter, "delimiter", "/", "separator of key segments, keys sharing the segment following the prefix are grouped into a common prefix, empty lists all keys")
+	Ls.Flags().IntVar(&lsLimit, "limit", 0, "limit number of listed prefixes and keys, 0 means unlimited")
+	Ls.Flags().Int64Var(&lsPageSize, "page-size", 1000, "number of keys retrieved by a single request")
+}
+
+// Ls is a subcommand used for browsing keys in a table hierarchically.
+var Ls = cobra.Command{
+	Use:   "ls <table> [prefix]",
+	Short: "List keys and common prefixes in Regatta store",
+	Long: "Lists keys with the given prefix hierarchically, similar to listing directories. Keys containing --delimiter after the prefix " +
+		"are grouped into common prefixes ending with the first such delimiter, other keys are listed individually.\n" +
+		"Keys are retrieved using paginated Range queries as defined in API (https://engineering.jamf.com/regatta/api/#range) without values, " +
+		"after a common prefix is found, the listing skips right past all keys sharing the common prefix, " +
+		"so that the prefixes containing large number of keys are listed quickly.\n" +
+		"When prefix is provided, it needs to be valid UTF-8 string, unless different encoding is selected using --key-encoding flag, " +
+		"a trailing asterisk (*) is optional.\n" +
+		"Listing is printed as JSON object with \"prefixes\" field containing common prefixes and \"keys\" field containing keys, " +
+		"when the listing is cut by --limit flag, \"truncated\" field is set.",
+	Example: "regatta-client ls table\n" +
+		"regatta-client ls table tenant/123/\n" +
+		"regatta-client ls --delimiter : --limit 100 table",
+	Args: cobra.MatchAll(cobra.MinimumNArgs(1), cobra.MaximumNArgs(2)),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := createClient()
+		if err != nil {
+			printError(cmd, "There was an error, while establishing connection to Regatta.", err)
+			return
+		}
+
+		var prefix []byte
+		if len(args) == 2 {
+			if prefix, _, err = parseKey(args[1], keyEncodingOption); err != nil {
+				printError(cmd, "There was an error while decoding parameters.", err)
+				return
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		listing, err := listKeys(ctx, client, []byte(args[0]), prefix, []byte(lsDelimiter), lsLimit)
+		if err != nil {
+			handleRegattaError(cmd, err)
+			return
+		}
+		marshal, _ := json.Marshal(listing)
+		cmd.Println(string(marshal))
+	},
+}
+
+type lsResult struct {
+	Prefixes  []lsPrefix `json:"prefixes"`
+	Keys      []lsKey    `json:"keys"`
+	Truncated bool       `json:"truncated,omitempty"`
+}
+
+type lsPrefix struct {
+	Prefix         string `json:"prefix"`
+	PrefixEncoding string `json:"prefix_encoding,omitempty"`
+}
+
+type lsKey struct {
+	Key         string `json:"key"`
+	KeyEncoding string `json:"key_encoding,omitempty"`
+}
+
+// listKeys lists common prefixes and keys with the given prefix. Common prefixes are skipped by continuing the scan
+// from the successor of the common prefix, so that keys sharing the common prefix are not retrieved.
+func listKeys(ctx context.Context, client regattapb.KVClient, table, prefix, delimiter []byte, limit int) (lsResult, error) {
+	result := lsResult{Prefixes: make([]lsPrefix, 0), Keys: make([]lsKey, 0)}
+	req := &regattapb.RangeRequest{Table: table, Key: zero, RangeEnd: zero, Limit: lsPageSize, KeysOnly: true}
+	if len(prefix) != 0 {
+		req.Key, req.RangeEnd = prefix, prefixRangeEnd(prefix)
+	}
+
+	var common []byte
+	for {
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		response, err := client.Range(timeoutCtx, req)
+		cancel()
+		if err != nil {
+			return lsResult{}, err
+		}
+		for _, kv := range response.Kvs {
+			if common != nil && bytes.HasPrefix(kv.Key, common) {
+				continue
+			}
+			if limit > 0 && len(result.Prefixes)+len(result.Keys) == limit {
+				result.Truncated = true
+				return result, nil
+			}
+			common = commonPrefix(kv.Key, prefix, delimiter)
+			if common != nil {
+				p := lsPrefix{}
+				p.Prefix, p.PrefixEncoding = outputKeyEncodingOption.encode(common)
+				result.Prefixes = append(result.Prefixes, p)
+				continue
+			}
+			k := lsKey{}
+			k.Key, k.KeyEncoding = outputKeyEncodingOption.encode(kv.Key)
+			result.Keys = append(result.Keys, k)
+		}
+		if !response.More || len(response.Kvs) == 0 {
+			return result, nil
+		}
+
+		last := response.Kvs[len(response.Kvs)-1].Key
+		if common == nil || !bytes.HasPrefix(last, common) {
+			// continue right after the last retrieved key
+			req.Key = append(last, 0)
+			continue
+		}
+		// skip right past all keys sharing the common prefix
+		next := prefixRangeEnd(common)
+		if bytes.Equal(next, zero) {
+			// the common prefix consists of 0xFF bytes only, so there is no key after it
+			return result, nil
+		}
+		req.Key = next
+	}
+}
+
+// commonPrefix returns the key cut after the first delimiter following the prefix, or nil when the key does not contain such delimiter.
+func commonPrefix(key, prefix, delimiter []byte) []byte {
+	if len(delimiter) == 0 {
+		return nil
+	}
+	idx := bytes.Index(key[len(prefix):], delimiter)
+	if idx < 0 {
+		return nil
+	}
+	return key[:len(prefix)+idx+len(delimiter)]
+}
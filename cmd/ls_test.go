@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jamf/regatta/regattapb"
+	"github.com/jamf/regatta/regattaserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+func Test_Ls(t *testing.T) {
+	defer resetLsFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: zero, RangeEnd: zero, Limit: 2, KeysOnly: true}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("a/1")}, {Key: []byte("a/2")}}, More: true}, nil)
+	// keys following "a/" are skipped
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("a0"), RangeEnd: zero, Limit: 2, KeysOnly: true}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("b")}, {Key: []byte("c/x/1")}}, More: true}, nil)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("c0"), RangeEnd: zero, Limit: 2, KeysOnly: true}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("d/")}}}, nil)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("c/"), RangeEnd: []byte("c0"), Limit: 2, KeysOnly: true}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("c/x/1")}, {Key: []byte("c/y")}}}, nil)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("a/2\x00"), RangeEnd: zero, Limit: 2, KeysOnly: true}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("b")}}}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "list table",
+			args: []string{"table"},
+			want: `{"prefixes":[{"prefix":"a/"},{"prefix":"c/"},{"prefix":"d/"}],"keys":[{"key":"b"}]}`,
+		},
+		{
+			name: "list prefix",
+			args: []string{"table", "c/"},
+			want: `{"prefixes":[{"prefix":"c/x/"}],"keys":[{"key":"c/y"}]}`,
+		},
+		{
+			name: "list prefix with asterisk",
+			args: []string{"table", "c/*"},
+			want: `{"prefixes":[{"prefix":"c/x/"}],"keys":[{"key":"c/y"}]}`,
+		},
+		{
+			name: "list table with limit",
+			args: []string{"table", "--limit", "2"},
+			want: `{"prefixes":[{"prefix":"a/"}],"keys":[{"key":"b"}],"truncated":true}`,
+		},
+		{
+			name: "list table without delimiter",
+			args: []string{"table", "--delimiter", "", "--limit", "3"},
+			want: `{"prefixes":[],"keys":[{"key":"a/1"},{"key":"a/2"},{"key":"b"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetLsFlags()
+
+			buf := new(bytes.Buffer)
+			RootCmd.SetOut(buf)
+			RootCmd.SetArgs(append([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "ls", "--page-size", "2"}, tt.args...))
+			RootCmd.Execute()
+
+			assert.JSONEq(t, tt.want, strings.TrimSpace(buf.String()))
+		})
+	}
+}
+
+func Test_Ls_BinaryPrefix(t *testing.T) {
+	defer resetLsFlags()
+	defer func() {
+		keyEncodingOption = utf8Encoding
+		outputKeyEncodingOption = outputEncodingType(utf8Encoding)
+	}()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+	// keys with prefix 0x41 0xff end before 0x42
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("A\xff"), RangeEnd: []byte("B"), Limit: 2, KeysOnly: true}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("A\xff/1")}, {Key: []byte("A\xff/2")}}, More: true}, nil)
+	// keys following "A\xff/" are skipped
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("A\xff0"), RangeEnd: []byte("B"), Limit: 2, KeysOnly: true}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("A\xff\xff")}}}, nil)
+	// keys with prefix 0xff 0xff end at the end of the table, there is no key after the common prefix 0xff 0xff 0xff
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("\xff\xff"), RangeEnd: zero, Limit: 2, KeysOnly: true}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("\xff\xff\xff")}, {Key: []byte("\xff\xff\xff\xff")}}, More: true}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "prefix ending with 0xff",
+			args: []string{"table", "41ff*"},
+			want: `{"prefixes":[{"prefix":"41ff2f"}],"keys":[{"key":"41ffff"}]}`,
+		},
+		{
+			name: "prefix of 0xff bytes",
+			args: []string{"--delimiter", "\xff", "table", "ffff"},
+			want: `{"prefixes":[{"prefix":"ffffff"}],"keys":[]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetLsFlags()
+
+			buf := new(bytes.Buffer)
+			RootCmd.SetOut(buf)
+			RootCmd.SetArgs(append([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "--key-encoding", "hex", "--output-key-encoding", "hex",
+				"ls", "--page-size", "2"}, tt.args...))
+			RootCmd.Execute()
+
+			assert.JSONEq(t, tt.want, strings.TrimSpace(buf.String()))
+		})
+	}
+	storage.AssertExpectations(t)
+}
+
+func Test_commonPrefix(t *testing.T) {
+	tests := []struct {
+		key    string
+		prefix string
+		want   []byte
+	}{
+		{key: "tenant/123/device/456", prefix: "", want: []byte("tenant/")},
+		{key: "tenant/123/device/456", prefix: "tenant/", want: []byte("tenant/123/")},
+		{key: "tenant/123", prefix: "tenant/", want: nil},
+		{key: "tenant/123/", prefix: "tenant/123/", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			assert.Equal(t, tt.want, commonPrefix([]byte(tt.key), []byte(tt.prefix), []byte("/")))
+		})
+	}
+}
+
+func resetLsFlags() {
+	lsDelimiter = "/"
+	lsLimit = 0
+	lsPageSize = 1000
+}
@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/jamf/regatta/regattapb"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	pingCount    int
+	pingInterval time.Duration
+)
+
+func init() {
+	Ping.Flags().IntVarP(&pingCount, "count", "c", 4, "number of pings sent to each endpoint")
+	Ping.Flags().DurationVar(&pingInterval, "interval", time.Second, "interval between pings")
+}
+
+// Ping is a subcommand used for checking connection to Regatta.
+var Ping = cobra.Command{
+	Use:   "ping [table]",
+	Short: "Check connection to Regatta",
+	Long: "Checks connection to all endpoints, each ping reports DNS resolution, TCP connection, TLS handshake and a round-trip of a cheap request to Regatta " +
+		"together with their durations. Each ping is printed as JSON object on a separate line, followed by the summary with round-trip latencies.\n" +
+		"TLS handshake reports negotiated TLS version, cipher suite and ALPN protocol, subject and expiration of the server certificate " +
+		"and the result of its verification using trusted CA certificates and pinned public keys.\n" +
+		"When table is provided, a single key of the table is retrieved, otherwise a request without table is sent, " +
+		"which is rejected by Regatta with InvalidArgument code without accessing any data, the rejection is considered a successful round-trip. " +
+		"Connection to Regatta is established only once for each endpoint and it is reused by all requests. " +
+		"The command exits with non-zero status, when no ping succeeds.",
+	Example: "regatta-client ping\n" +
+		"regatta-client ping -c 10 --interval 100ms table",
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		endpoints, err := parseEndpoints(endpointOption)
+		if err != nil {
+			printError(cmd, "There was an error while decoding parameters.", err)
+			return
+		}
+		cfg, err := newTLSConfig()
+		if err != nil {
+			printError(cmd, "There was an error while loading certificates.", err)
+			return
+		}
+		pins, err := parsePins(pinsOption)
+		if err != nil {
+			printError(cmd, "There was an error while decoding parameters.", err)
+			return
+		}
+		var table string
+		if len(args) == 1 {
+			table = args[0]
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		p := &pinger{table: table, tlsConfig: cfg, pins: pins, clients: make(map[string]regattapb.KVClient)}
+		defer p.close()
+		summary := pingSummary{}
+		for seq := 1; seq <= pingCount && ctx.Err() == nil; seq++ {
+			if seq > 1 {
+				select {
+				case <-ctx.Done():
+				case <-time.After(pingInterval):
+				}
+			}
+			for _, endpoint := range endpoints {
+				if ctx.Err() != nil {
+					break
+				}
+				result := p.ping(ctx, seq, endpoint)
+				summary.add(result)
+				marshal, _ := json.Marshal(result)
+				cmd.Println(string(marshal))
+			}
+		}
+		if summary.succeeded == 0 {
+			exitStatus = 1
+		}
+		marshal, _ := json.Marshal(map[string]any{"summary": summary.report()})
+		cmd.Println(string(marshal))
+	},
+}
+
+type pingResult struct {
+	Seq      int      `json:"seq"`
+	Endpoint string   `json:"endpoint"`
+	DNS      *pingDNS `json:"dns,omitempty"`
+	TCP      *pingTCP `json:"tcp,omitempty"`
+	TLS      *pingTLS `json:"tls,omitempty"`
+	RPC      *pingRPC `json:"rpc,omitempty"`
+	Error    string   `json:"error,omitempty"`
+
+	succeeded bool
+	latency   time.Duration
+}
+
+type pingDNS struct {
+	Addresses []string `json:"addresses"`
+	Duration  string   `json:"duration"`
+}
+
+type pingTCP struct {
+	Address  string `json:"address"`
+	Proxy    string `json:"proxy,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type pingTLS struct {
+	Version           string    `json:"version"`
+	CipherSuite       string    `json:"cipher_suite"`
+	ALPN              string    `json:"alpn,omitempty"`
+	Subject           string    `json:"subject,omitempty"`
+	NotAfter          time.Time `json:"not_after,omitempty"`
+	ExpiresIn         string    `json:"expires_in,omitempty"`
+	Verified          bool      `json:"verified"`
+	VerificationError string    `json:"verification_error,omitempty"`
+	Duration          string    `json:"duration"`
+}
+
+type pingRPC struct {
+	Code     string `json:"code"`
+	Message  string `json:"message,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// pinger checks connection to endpoints, the clients used for round-trips are created once for each endpoint.
+type pinger struct {
+	table     string
+	tlsConfig *tls.Config
+	pins      [][]byte
+	clients   map[string]regattapb.KVClient
+	conns     []*endpointsConn
+}
+
+func (p *pinger) ping(ctx context.Context, seq int, endpoint string) pingResult {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	result := pingResult{Seq: seq, Endpoint: endpoint}
+
+	proxyURL, err := endpointProxy(endpoint)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if !isUnixEndpoint(endpoint) && proxyURL == nil {
+		host, _, _ := net.SplitHostPort(endpointAddress(endpoint))
+		start := time.Now()
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			result.Error = "DNS resolution failed: " + err.Error()
+			return result
+		}
+		result.DNS = &pingDNS{Addresses: addrs, Duration: time.Since(start).String()}
+	}
+
+	start := time.Now()
+	conn, err := proxyContextDialer(proxyURL)(ctx, endpointAddress(endpoint))
+	if err != nil {
+		result.Error = "TCP connection failed: " + err.Error()
+		return result
+	}
+	defer conn.Close()
+	result.TCP = &pingTCP{Address: conn.RemoteAddr().String(), Duration: time.Since(start).String()}
+	if proxyURL != nil {
+		result.TCP.Proxy = proxyURL.Redacted()
+	}
+
+	start = time.Now()
+	inspected := tlsInspectResult{ServerName: endpointServerName(endpoint)}
+	if err := handshakeTLS(ctx, conn, &inspected, p.tlsConfig, p.pins); err != nil {
+		result.Error = "TLS handshake failed: " + err.Error()
+		return result
+	}
+	result.TLS = &pingTLS{
+		Version:           inspected.Version,
+		CipherSuite:       inspected.CipherSuite,
+		ALPN:              inspected.ALPN,
+		Verified:          inspected.Verified,
+		VerificationError: inspected.VerificationError,
+		Duration:          time.Since(start).String(),
+	}
+	if len(inspected.Chain) != 0 {
+		result.TLS.Subject = inspected.Chain[0].Subject
+		result.TLS.NotAfter = inspected.Chain[0].NotAfter
+		result.TLS.ExpiresIn = time.Until(inspected.Chain[0].NotAfter).Round(time.Minute).String()
+	}
+
+	client, err := p.client(ctx, endpoint)
+	if err != nil {
+		result.Error = "connection to Regatta failed: " + err.Error()
+		return result
+	}
+	req := &regattapb.RangeRequest{Table: []byte(p.table), Key: zero, RangeEnd: zero, Limit: 1, KeysOnly: true}
+	start = time.Now()
+	_, err = client.Range(ctx, req)
+	result.latency = time.Since(start)
+	st := status.Convert(err)
+	result.RPC = &pingRPC{Code: st.Code().String(), Message: st.Message(), Duration: result.latency.String()}
+	result.succeeded = p.responded(st.Code())
+	return result
+}
+
+// responded reports whether the code of the round-trip shows that Regatta handled the request.
+func (p *pinger) responded(code codes.Code) bool {
+	if len(p.table) == 0 {
+		return code == codes.InvalidArgument
+	}
+	return code == codes.OK || code == codes.NotFound
+}
+
+// client returns client connected to the endpoint, the connection is established in blocking manner,
+// so that the reason of connection failure is reported.
+func (p *pinger) client(ctx context.Context, endpoint string) (regattapb.KVClient, error) {
+	if client, ok := p.clients[endpoint]; ok {
+		return client, nil
+	}
+	conn, err := dialEndpoints(ctx, []string{endpoint}, grpc.WithBlock(), grpc.WithReturnConnectionError())
+	if err != nil {
+		return nil, err
+	}
+	p.conns = append(p.conns, conn)
+	p.clients[endpoint] = regattapb.NewKVClient(conn)
+	return p.clients[endpoint], nil
+}
+
+func (p *pinger) close() {
+	for _, conn := range p.conns {
+		_ = conn.Close()
+	}
+}
+
+type pingSummary struct {
+	sent      int
+	succeeded int
+	latencies []time.Duration
+}
+
+func (s *pingSummary) add(result pingResult) {
+	s.sent++
+	if result.succeeded {
+		s.succeeded++
+		s.latencies = append(s.latencies, result.latency)
+	}
+}
+
+type pingReport struct {
+	Sent      int    `json:"sent"`
+	Succeeded int    `json:"succeeded"`
+	Failed    int    `json:"failed"`
+	Min       string `json:"min,omitempty"`
+	Avg       string `json:"avg,omitempty"`
+	Max       string `json:"max,omitempty"`
+}
+
+func (s *pingSummary) report() pingReport {
+	report := pingReport{Sent: s.sent, Succeeded: s.succeeded, Failed: s.sent - s.succeeded}
+	if len(s.latencies) == 0 {
+		return report
+	}
+	minLatency, maxLatency, total := s.latencies[0], s.latencies[0], time.Duration(0)
+	for _, l := range s.latencies {
+		minLatency = min(minLatency, l)
+		maxLatency = max(maxLatency, l)
+		total += l
+	}
+	report.Min = minLatency.String()
+	report.Avg = (total / time.Duration(len(s.latencies))).String()
+	report.Max = maxLatency.String()
+	return report
+}
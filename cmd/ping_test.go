@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jamf/regatta/regattapb"
+	"github.com/jamf/regatta/regattaserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+func Test_Ping(t *testing.T) {
+	defer resetPingFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte{0}, RangeEnd: []byte{0}, Limit: 1, KeysOnly: true}).
+		Return(&regattapb.RangeResponse{}, nil)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode string
+	}{
+		{
+			name:     "ping with table",
+			args:     []string{"table"},
+			wantCode: "OK",
+		},
+		{
+			name:     "ping without table",
+			wantCode: "InvalidArgument",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetPingFlags()
+
+			buf := new(bytes.Buffer)
+			RootCmd.SetOut(buf)
+			RootCmd.SetArgs(append([]string{"ping", "--endpoint", lis.Addr().String(), "--cert", "test.crt", "-c", "2", "--interval", "10ms"}, tt.args...))
+			require.Equal(t, 0, executeCommand())
+
+			lines := readJSONLines(t, buf)
+			require.Len(t, lines, 3)
+			for i, line := range lines[:2] {
+				var result pingResult
+				require.NoError(t, json.Unmarshal(line, &result))
+				assert.Equal(t, i+1, result.Seq)
+				assert.Empty(t, result.Error)
+				require.NotNil(t, result.DNS)
+				assert.Equal(t, []string{"127.0.0.1"}, result.DNS.Addresses)
+				require.NotNil(t, result.TCP)
+				assert.Equal(t, lis.Addr().String(), result.TCP.Address)
+				require.NotNil(t, result.TLS)
+				assert.Equal(t, "TLS 1.3", result.TLS.Version)
+				assert.Equal(t, "h2", result.TLS.ALPN)
+				assert.True(t, result.TLS.Verified)
+				require.NotNil(t, result.RPC)
+				assert.Equal(t, tt.wantCode, result.RPC.Code)
+			}
+
+			var summary struct {
+				Summary pingReport `json:"summary"`
+			}
+			require.NoError(t, json.Unmarshal(lines[2], &summary))
+			assert.Equal(t, 2, summary.Summary.Sent)
+			assert.Equal(t, 2, summary.Summary.Succeeded)
+			assert.Equal(t, 0, summary.Summary.Failed)
+			assert.NotEmpty(t, summary.Summary.Avg)
+		})
+	}
+}
+
+func Test_Ping_Unreachable(t *testing.T) {
+	defer resetPingFlags()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	require.NoError(t, lis.Close())
+
+	buf := new(bytes.Buffer)
+	RootCmd.SetOut(buf)
+	RootCmd.SetArgs([]string{"ping", "--endpoint", addr, "--cert", "test.crt", "-c", "1"})
+	assert.Equal(t, 1, executeCommand())
+
+	lines := readJSONLines(t, buf)
+	require.Len(t, lines, 2)
+	var result pingResult
+	require.NoError(t, json.Unmarshal(lines[0], &result))
+	assert.NotNil(t, result.DNS)
+	assert.Nil(t, result.TCP)
+	assert.Contains(t, result.Error, "TCP connection failed")
+	assert.JSONEq(t, `{"summary":{"sent":1,"succeeded":0,"failed":1}}`, string(lines[1]))
+}
+
+func readJSONLines(t *testing.T, buf *bytes.Buffer) [][]byte {
+	t.Helper()
+	var lines [][]byte
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		lines = append(lines, append([]byte(nil), scanner.Bytes()...))
+	}
+	require.NoError(t, scanner.Err())
+	return lines
+}
+
+func resetPingFlags() {
+	pingCount = 4
+	pingInterval = time.Second
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// protectedEndpointsEnv is the environment variable containing comma-separated patterns of protected endpoints.
+const protectedEndpointsEnv = "REGATTA_PROTECTED_ENDPOINTS"
+
+// protectedEndpointsFile returns path of the configuration file containing patterns of protected endpoints, one pattern per line.
+func protectedEndpointsFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "regatta-client", "protected-endpoints"), nil
+}
+
+// protectedPatterns returns patterns of protected endpoints configured by the environment variable and the configuration file,
+// missing configuration file is not an error. Empty lines and lines starting with # in the file are ignored.
+func protectedPatterns() ([]string, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(os.Getenv(protectedEndpointsEnv), ",") {
+		if pattern = strings.TrimSpace(pattern); len(pattern) != 0 {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	name, err := protectedEndpointsFile()
+	if err != nil {
+		// without configuration directory, only the environment variable is used
+		return patterns, nil
+	}
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return patterns, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
+
+// isProtected reports whether any of the endpoints matches one of the patterns of protected endpoints.
+// Patterns use the syntax of path.Match, e.g. "*.prod.example.com:443".
+func isProtected(endpoints []string) (bool, error) {
+	patterns, err := protectedPatterns()
+	if err != nil {
+		return false, err
+	}
+	for _, pattern := range patterns {
+		for _, endpoint := range endpoints {
+			matched, err := path.Match(pattern, endpoint)
+			if err != nil {
+				return false, fmt.Errorf("invalid pattern of protected endpoint %q: %w", pattern, err)
+			}
+			if matched {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+// proxyFor returns URL of the proxy used for connecting to the given address, or nil when the address is connected directly.
+// The proxy is either provided explicitly, or it is taken from HTTPS_PROXY or ALL_PROXY environment variables,
+// respecting NO_PROXY environment variable.
+func proxyFor(explicit, addr string) (*url.URL, error) {
+	if len(explicit) != 0 {
+		u, err := url.Parse(explicit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy: %w", err)
+		}
+		return u, checkProxyScheme(u)
+	}
+
+	cfg := httpproxy.FromEnvironment()
+	if len(cfg.HTTPSProxy) == 0 {
+		cfg.HTTPSProxy = getEnvAny("ALL_PROXY", "all_proxy")
+	}
+	u, err := cfg.ProxyFunc()(&url.URL{Scheme: "https", Host: addr})
+	if err != nil || u == nil {
+		return nil, err
+	}
+	return u, checkProxyScheme(u)
+}
+
+// endpointProxy returns URL of the proxy used for connecting to the endpoint, endpoints with scheme are connected directly.
+func endpointProxy(endpoint string) (*url.URL, error) {
+	if hasScheme(endpoint) {
+		return nil, nil
+	}
+	return proxyFor(proxyOption, endpoint)
+}
+
+func checkProxyScheme(u *url.URL) error {
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return nil
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q, supported schemes are \"http\", \"https\", \"socks5\" and \"socks5h\"", u.Scheme)
+	}
+}
+
+func getEnvAny(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); len(value) != 0 {
+			return value
+		}
+	}
+	return ""
+}
+
+// proxyContextDialer returns dialer connecting through HTTP CONNECT or SOCKS5 proxy, or connecting directly when the proxy is nil.
+// Unix domain sockets are always connected directly.
+func proxyContextDialer(u *url.URL) func(context.Context, string) (net.Conn, error) {
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		d := &net.Dialer{}
+		if socket, ok := unixSocketAddress(addr); ok {
+			return d.DialContext(ctx, "unix", socket)
+		}
+		if u == nil {
+			return d.DialContext(ctx, "tcp", addr)
+		}
+
+		switch u.Scheme {
+		case "socks5", "socks5h":
+			var auth *proxy.Auth
+			if u.User != nil {
+				password, _ := u.User.Password()
+				auth = &proxy.Auth{User: u.User.Username(), Password: password}
+			}
+			socks, err := proxy.SOCKS5("tcp", u.Host, auth, d)
+			if err != nil {
+				return nil, err
+			}
+			return socks.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+		default:
+			return dialHTTPConnect(ctx, d, u, addr)
+		}
+	}
+}
+
+// dialHTTPConnect connects to the address through HTTP proxy using CONNECT method.
+func dialHTTPConnect(ctx context.Context, d *net.Dialer, u *url.URL, addr string) (net.Conn, error) {
+	host := u.Host
+	if len(u.Port()) == 0 {
+		port := "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "https" {
+		conn = tls.Client(conn, &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12})
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Host: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(u.User.Username()+":"+password)))
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to connect through proxy: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to connect through proxy: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused connection to %s: %s", addr, resp.Status)
+	}
+	if br.Buffered() != 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a connection with data already read into the buffer.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
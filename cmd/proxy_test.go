@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jamf/regatta/regattapb"
+	"github.com/jamf/regatta/regattaserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+func Test_proxyFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		explicit string
+		env      map[string]string
+		addr     string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name: "no proxy",
+			addr: "regatta.example.com:8443",
+		},
+		{
+			name:     "explicit proxy",
+			explicit: "socks5://bastion.example.com:1080",
+			env:      map[string]string{"HTTPS_PROXY": "http://proxy.example.com:3128"},
+			addr:     "regatta.example.com:8443",
+			want:     "socks5://bastion.example.com:1080",
+		},
+		{
+			name:     "unsupported proxy scheme",
+			explicit: "ftp://proxy.example.com",
+			addr:     "regatta.example.com:8443",
+			wantErr:  true,
+		},
+		{
+			name: "HTTPS_PROXY environment variable",
+			env:  map[string]string{"HTTPS_PROXY": "http://proxy.example.com:3128", "ALL_PROXY": "socks5://bastion.example.com:1080"},
+			addr: "regatta.example.com:8443",
+			want: "http://proxy.example.com:3128",
+		},
+		{
+			name: "ALL_PROXY environment variable",
+			env:  map[string]string{"all_proxy": "socks5://bastion.example.com:1080"},
+			addr: "regatta.example.com:8443",
+			want: "socks5://bastion.example.com:1080",
+		},
+		{
+			name: "NO_PROXY environment variable",
+			env:  map[string]string{"ALL_PROXY": "socks5://bastion.example.com:1080", "NO_PROXY": ".internal.example.com"},
+			addr: "regatta.internal.example.com:8443",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "ALL_PROXY", "all_proxy", "NO_PROXY", "no_proxy"} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			u, err := proxyFor(tt.explicit, tt.addr)
+
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if len(tt.want) == 0 {
+				assert.Nil(t, u)
+				return
+			}
+			require.NotNil(t, u)
+			assert.Equal(t, tt.want, u.String())
+		})
+	}
+}
+
+func Test_Range_Proxy(t *testing.T) {
+	tests := []struct {
+		name  string
+		serve func(conn net.Conn) (string, error)
+		proxy string
+	}{
+		{
+			name:  "HTTP CONNECT proxy",
+			serve: serveHTTPConnect,
+			proxy: "http://user:password@",
+		},
+		{
+			name:  "SOCKS5 proxy",
+			serve: serveSOCKS5,
+			proxy: "socks5://",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetRangeFlags()
+			defer func() { proxyOption = "" }()
+
+			lis, err := net.Listen("tcp", "localhost:0")
+			require.NoError(t, err)
+			s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+			storage := new(mockKVService)
+			storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("key")}).
+				Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("key"), Value: []byte("value")}}}, nil)
+
+			regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+			go s.Serve(lis)
+			defer s.Stop()
+
+			proxied := make(chan string, 1)
+			proxyLis := startTestProxy(t, tt.serve, proxied)
+
+			buf := new(bytes.Buffer)
+			RootCmd.SetOut(buf)
+			RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "--proxy", tt.proxy + proxyLis.Addr().String(), "range", "table", "key"})
+			RootCmd.Execute()
+
+			assert.Equal(t, `[{"key":"key","value":"value"}]`, strings.TrimSpace(buf.String()))
+			assert.Equal(t, lis.Addr().String(), <-proxied)
+		})
+	}
+}
+
+// startTestProxy starts proxy, which connects to the address requested by the client using serve function
+// and reports the address into proxied channel.
+func startTestProxy(t *testing.T, serve func(conn net.Conn) (string, error), proxied chan<- string) net.Listener {
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				addr, err := serve(conn)
+				if err != nil {
+					return
+				}
+				select {
+				case proxied <- addr:
+				default:
+				}
+				target, err := net.Dial("tcp", addr)
+				if err != nil {
+					return
+				}
+				defer target.Close()
+				go io.Copy(target, conn)
+				io.Copy(conn, target)
+			}()
+		}
+	}()
+	return lis
+}
+
+func serveHTTPConnect(conn net.Conn) (string, error) {
+	req, err := http.ReadRequest(bufio.NewReader(conn))
+	if err != nil {
+		return "", err
+	}
+	if req.Method != http.MethodConnect || req.Header.Get("Proxy-Authorization") != "Basic dXNlcjpwYXNzd29yZA==" {
+		_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+		return "", fmt.Errorf("unexpected request %s", req.Method)
+	}
+	_, err = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+	return req.Host, err
+}
+
+// serveSOCKS5 handles SOCKS5 handshake without authentication and CONNECT command.
+func serveSOCKS5(conn net.Conn) (string, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", err
+	}
+	if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+		return "", err
+	}
+	if _, err := conn.Write([]byte{5, 0}); err != nil {
+		return "", err
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return "", err
+	}
+	var host string
+	switch request[3] {
+	case 1:
+		ip := make([]byte, 4)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case 3:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", err
+		}
+		name := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return "", err
+		}
+		host = string(name)
+	default:
+		return "", fmt.Errorf("unsupported address type %d", request[3])
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", err
+	}
+	_, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), err
+}
@@ -3,70 +3,117 @@ package cmd
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
 	"time"
 
 	"github.com/jamf/regatta/regattapb"
 	"github.com/spf13/cobra"
-	"google.golang.org/grpc"
 )
 
 var (
-	putBinary   bool
-	putCompress = gzipCompress
+	putBinary bool
+	putPrevKv bool
+
+	putFromFile        bool
+	putEncode          = noCodec
+	putProtoDescriptor string
+	putMessage         string
+	putValueCodec      = noValueCodec
 )
 
 func init() {
 	Put.Flags().BoolVar(&putBinary, "binary", false, "provided <value> is binary data encoded using Base64")
-	Put.Flags().Var(&putCompress, "compress", `use compression, allowed values: "gzip", "snappy" and "none"`)
-	Put.RegisterFlagCompletionFunc("compress", compressTypeCompletion)
+	Put.Flags().BoolVar(&putPrevKv, "prev-kv", false, "print the previous item replaced by this put as JSON object, with --binary the key and value are encoded as Base64 strings")
+	Put.Flags().BoolVar(&putFromFile, "from-file", false, "provided <value> is a path to the file containing the value, file content is used as is")
+	Put.Flags().Var(&putEncode, "encode", `encode provided <value>, which is JSON document, before storing it, allowed values: "json", "protobuf", "msgpack", "cbor" and "none"`)
+	Put.RegisterFlagCompletionFunc("encode", codecTypeCompletion)
+	Put.Flags().StringVar(&putProtoDescriptor, "proto-descriptor", "", "file containing FileDescriptorSet used for encoding protobuf values")
+	Put.Flags().StringVar(&putMessage, "message", "", "full name of protobuf message type used for encoding protobuf values")
+	Put.Flags().Var(&putValueCodec, "value-codec", `compress value before storing it, allowed values: "gzip", "zstd", "snappy", "lz4" and "none"`)
+	Put.RegisterFlagCompletionFunc("value-codec", valueCodecTypeCompletion)
+	Put.MarkFlagsMutuallyExclusive("binary", "encode")
+	Put.MarkFlagsMutuallyExclusive("binary", "from-file")
 }
 
 // Put is a subcommand used for creating/updating records in a table.
 var Put = cobra.Command{
-	Use:     "put <table> <key> <value>",
-	Short:   "Put data into Regatta store",
-	Long:    "Put data into Regatta store using Put query as defined in API (https://engineering.jamf.com/regatta/api/#put).",
-	Example: "regatta-client put table key value",
-	Args:    cobra.MatchAll(cobra.ExactArgs(3)),
+	Use:   "put <table> <key> <value>",
+	Short: "Put data into Regatta store",
+	Long: "Put data into Regatta store using Put query as defined in API (https://engineering.jamf.com/regatta/api/#put).\n" +
+		"When --prev-kv flag is provided, the item replaced by this put is printed in the same format as items retrieved by range command. " +
+		"If there was no such item, nothing is printed.\n" +
+		"Value can be provided as JSON document and encoded using --encode flag before storing it, " +
+		"protobuf values are encoded using the message type provided by --message flag described in FileDescriptorSet provided by --proto-descriptor flag. " +
+		"The value is validated before sending any request to Regatta.\n" +
+		"Value can be compressed before storing it using --value-codec flag.",
+	Example: "regatta-client put table key value\n" +
+		"regatta-client put --prev-kv table key value\n" +
+		"regatta-client put --encode msgpack table key '{\"field\": 1}'\n" +
+		"regatta-client put --encode protobuf --proto-descriptor descriptor.pb --message example.Message --from-file table key value.json\n" +
+		"regatta-client put --value-codec zstd table key value",
+	Args: cobra.MatchAll(cobra.ExactArgs(3)),
 	Run: func(cmd *cobra.Command, args []string) {
+		req, err := createPutRequest(args)
+		if err != nil {
+			printError(cmd, "There was an error while decoding parameters.", err)
+			return
+		}
+
 		client, err := createClient()
 		if err != nil {
-			cmd.PrintErrln("There was an error, while establishing connection to Regatta.", err)
+			printError(cmd, "There was an error, while establishing connection to Regatta.", err)
 			return
 		}
 
 		timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
-
-		req, err := createPutRequest(args)
+		response, err := client.Put(timeoutCtx, req)
 		if err != nil {
-			cmd.PrintErrln("There was an error while decoding parameters.", err)
+			handleRegattaError(cmd, err)
 			return
 		}
-		var callOpts []grpc.CallOption
-		if putCompress != noCompress {
-			callOpts = append(callOpts, grpc.UseCompressor(putCompress.String()))
-		}
-		_, err = client.Put(timeoutCtx, req, callOpts...)
-		if err != nil {
-			handleRegattaError(cmd, err)
+
+		if putPrevKv && response.PrevKv != nil {
+			marshal, _ := json.Marshal(newRangeCommandResult(response.PrevKv, putBinary))
+			cmd.Println(string(marshal))
 		}
 	},
 }
 
 func createPutRequest(args []string) (*regattapb.PutRequest, error) {
 	table := []byte(args[0])
-	key := []byte(args[1])
+	key, err := keyEncodingOption.decode(args[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s key: %w", keyEncodingOption, err)
+	}
 	var value []byte
-	if putBinary {
-		var err error
+	switch {
+	case putFromFile:
+		value, err = os.ReadFile(args[2])
+	case putBinary:
 		value, err = base64.StdEncoding.DecodeString(args[2])
+	default:
+		value = []byte(args[2])
+	}
+	if err != nil {
+		return nil, err
+	}
+	encoder, err := newValueEncoder(putEncode, putProtoDescriptor, putMessage)
+	if err != nil {
+		return nil, err
+	}
+	if encoder != nil {
+		value, err = encoder(value)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("invalid %s value: %w", putEncode, err)
 		}
-	} else {
-		value = []byte(args[2])
+	}
+	value, err = putValueCodec.compress(value)
+	if err != nil {
+		return nil, err
 	}
 
-	return &regattapb.PutRequest{Table: table, Key: key, Value: value}, nil
+	return &regattapb.PutRequest{Table: table, Key: key, Value: value, PrevKv: putPrevKv}, nil
 }
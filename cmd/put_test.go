@@ -3,12 +3,15 @@ package cmd
 import (
 	"bytes"
 	"net"
+	"strings"
 	"testing"
 
 	"github.com/jamf/regatta/regattapb"
 	"github.com/jamf/regatta/regattaserver"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 )
@@ -35,6 +38,81 @@ func Test_Put(t *testing.T) {
 	storage.AssertExpectations(t)
 }
 
+func Test_Put_PrevKv(t *testing.T) {
+	resetPutFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+	storage.On("Put", mock.Anything, &regattapb.PutRequest{Table: []byte("table"), Key: []byte("key"), Value: []byte("data"), PrevKv: true}).
+		Return(&regattapb.PutResponse{PrevKv: &regattapb.KeyValue{Key: []byte("key"), Value: []byte("old-data"), CreateRevision: 1, ModRevision: 2}}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	buf := new(bytes.Buffer)
+	RootCmd.SetOut(buf)
+	RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "put", "--prev-kv", "table", "key", "data"})
+	RootCmd.Execute()
+
+	storage.AssertExpectations(t)
+	assert.Equal(t, `{"key":"key","value":"old-data","create_revision":1,"mod_revision":2}`, strings.TrimSpace(buf.String()))
+}
+
+func Test_Put_Encode(t *testing.T) {
+	resetPutFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	value, err := msgpack.Marshal(map[string]any{"field": int64(1)})
+	require.NoError(t, err)
+	storage := new(mockKVService)
+	storage.On("Put", mock.Anything, &regattapb.PutRequest{Table: []byte("table"), Key: []byte("key"), Value: value}).
+		Return(&regattapb.PutResponse{}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "put", "--encode", "msgpack", "table", "key", `{"field": 1}`})
+	RootCmd.Execute()
+
+	storage.AssertExpectations(t)
+}
+
+func Test_Put_Encode_InvalidValue(t *testing.T) {
+	resetPutFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	errBuf := new(bytes.Buffer)
+	RootCmd.SetErr(errBuf)
+	RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "put", "--encode", "msgpack", "table", "key", `{"field"`})
+	RootCmd.Execute()
+
+	storage.AssertNotCalled(t, "Put", mock.Anything, mock.Anything)
+	assert.Contains(t, errBuf.String(), "invalid msgpack value")
+}
+
 func resetPutFlags() {
 	putBinary = false
+	putPrevKv = false
+	putFromFile = false
+	putEncode = noCodec
+	putProtoDescriptor = ""
+	putMessage = ""
+	putValueCodec = noValueCodec
 }
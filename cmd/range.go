@@ -2,20 +2,26 @@ package cmd
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
-	"strings"
-	"time"
 
 	"github.com/jamf/regatta/regattapb"
 	"github.com/spf13/cobra"
-	"google.golang.org/grpc"
 )
 
 var (
 	rangeBinary   bool
 	rangeLimit    int64
-	rangeCompress = gzipCompress
+	rangePageSize int64
+
+	rangeMinModRevision    int64
+	rangeMaxModRevision    int64
+	rangeMinCreateRevision int64
+	rangeMaxCreateRevision int64
+
+	rangeDecode          = noCodec
+	rangeProtoDescriptor string
+	rangeMessage         string
+	rangeValueCodec      = noValueCodec
 
 	zero = []byte{0}
 )
@@ -23,8 +29,17 @@ var (
 func init() {
 	Range.Flags().BoolVar(&rangeBinary, "binary", false, "avoid decoding keys and values into UTF-8 strings, but rather encode them as Base64 strings")
 	Range.Flags().Int64Var(&rangeLimit, "limit", 0, "limit number of returned items")
-	Range.Flags().Var(&rangeCompress, "compress", `use compression, allowed values: "gzip", "snappy" and "none"`)
-	Range.RegisterFlagCompletionFunc("compress", compressTypeCompletion)
+	Range.Flags().Int64Var(&rangePageSize, "page-size", 1000, "number of items retrieved by a single request, when revision filters are applied")
+	Range.Flags().Int64Var(&rangeMinModRevision, "min-mod-revision", 0, "filter out items with modification revision lower than the given revision")
+	Range.Flags().Int64Var(&rangeMaxModRevision, "max-mod-revision", 0, "filter out items with modification revision greater than the given revision")
+	Range.Flags().Int64Var(&rangeMinCreateRevision, "min-create-revision", 0, "filter out items with creation revision lower than the given revision")
+	Range.Flags().Int64Var(&rangeMaxCreateRevision, "max-create-revision", 0, "filter out items with creation revision greater than the given revision")
+	Range.Flags().Var(&rangeDecode, "decode", `decode values and embed them into output as JSON, allowed values: "json", "protobuf", "msgpack", "cbor" and "none"`)
+	Range.RegisterFlagCompletionFunc("decode", codecTypeCompletion)
+	Range.Flags().StringVar(&rangeProtoDescriptor, "proto-descriptor", "", "file containing FileDescriptorSet used for decoding protobuf values")
+	Range.Flags().StringVar(&rangeMessage, "message", "", "full name of protobuf message type used for decoding protobuf values")
+	Range.Flags().Var(&rangeValueCodec, "value-codec", `decompress values compressed by the client, allowed values: "gzip", "zstd", "snappy", "lz4", "auto" and "none"`)
+	Range.RegisterFlagCompletionFunc("value-codec", valueCodecTypeCompletion)
 }
 
 // Range is a subcommand used for retrieving records from a table.
@@ -35,36 +50,62 @@ var Range = cobra.Command{
 		"You can either retrieve all items from the Regatta by providing no key.\n" +
 		"Or you can query for a single item in Regatta by providing item's key.\n" +
 		"Or you can query for all items with given prefix, by providing the given prefix and adding the asterisk (*) to the prefix.\n" +
-		"When key or prefix is provided, it needs to be valid UTF-8 string.\n" +
+		"When key or prefix is provided, it needs to be valid UTF-8 string, unless different encoding is selected using --key-encoding flag.\n" +
 		"Retrieved items are serialized into JSON array, where each item is a JSON object with \"key\" field representing key in Regatta " +
-		"and \"value\" field representing value stored under the given key in Regatta.",
+		"and \"value\" field representing value stored under the given key in Regatta. " +
+		"Keys and values are printed as UTF-8 strings, unless different encoding is selected using --output-key-encoding and --output-value-encoding flags, " +
+		"with \"auto\" encoding the used encoding is shown in \"key_encoding\" and \"value_encoding\" fields. " +
+		"Fields \"create_revision\" and \"mod_revision\" contain revisions in which the item was created and last modified, " +
+		"if they are provided by Regatta.\n" +
+		"Items can be filtered by their revisions using --min-mod-revision, --max-mod-revision, --min-create-revision and --max-create-revision flags. " +
+		"The filters are applied by the client to the revisions returned by Regatta, as Regatta does not support them, " +
+		"so the range is scanned in pages of --page-size items until --limit of matching items is reached, each page is retrieved with its own timeout, " +
+		"and items without revisions never match minimal revision filters.\n" +
+		"Values can be decoded using --decode flag and embedded into the output as JSON documents, " +
+		"protobuf values are decoded using the message type provided by --message flag described in FileDescriptorSet provided by --proto-descriptor flag. " +
+		"Compressed values can be decompressed before decoding using --value-codec flag, with \"auto\" the compression is detected using magic bytes. " +
+		"When value cannot be decompressed or decoded, it is printed as usual and the error is shown in \"decode_error\" field.",
 	Example: "regatta-client range table\n" +
 		"regatta-client range table key\n" +
-		"regatta-client range table 'prefix*'",
+		"regatta-client range table 'prefix*'\n" +
+		"regatta-client range --min-mod-revision 100 table\n" +
+		"regatta-client range --decode protobuf --proto-descriptor descriptor.pb --message example.Message table",
 	Args: cobra.MatchAll(cobra.MinimumNArgs(1), cobra.MaximumNArgs(2)),
 	Run: func(cmd *cobra.Command, args []string) {
 		client, err := createClient()
 		if err != nil {
-			cmd.PrintErrln("There was an error, while establishing connection to Regatta.", err)
+			printError(cmd, "There was an error, while establishing connection to Regatta.", err)
 			return
 		}
 
-		timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		req := createRangeRequest(args)
-		var callOpts []grpc.CallOption
-		if rangeCompress != noCompress {
-			callOpts = append(callOpts, grpc.UseCompressor(rangeCompress.String()))
+		req, err := createRangeRequest(args)
+		if err != nil {
+			printError(cmd, "There was an error while decoding parameters.", err)
+			return
 		}
-		response, err := client.Range(timeoutCtx, req, callOpts...)
+		decoder, err := newValueDecoder(rangeDecode, rangeProtoDescriptor, rangeMessage)
+		if err != nil {
+			printError(cmd, "There was an error while loading value decoder.", err)
+			return
+		}
+		kvs, err := rangeFiltered(cmd.Context(), client, req, newRevisionFilter(), rangePageSize)
 		if err != nil {
 			handleRegattaError(cmd, err)
 			return
 		}
 
 		results := make([]rangeCommandResult, 0)
-		for _, kv := range response.Kvs {
-			results = append(results, rangeCommandResult{Key: getValue(kv.Key), Value: getValue(kv.Value)})
+		for _, kv := range kvs {
+			var decompressErr error
+			kv.Value, decompressErr = rangeValueCodec.decompress(kv.Value)
+			result := newRangeCommandResult(kv, rangeBinary)
+			switch {
+			case decompressErr != nil:
+				result.DecodeError = decompressErr.Error()
+			case decoder != nil:
+				result.decodeValue(kv.Value, decoder)
+			}
+			results = append(results, result)
 		}
 		marshal, _ := json.Marshal(results)
 		cmd.Println(string(marshal))
@@ -72,52 +113,106 @@ var Range = cobra.Command{
 }
 
 type rangeCommandResult struct {
-	Key   string `json:"key"`
-	Value string `json:"value"`
+	Key            string `json:"key"`
+	KeyEncoding    string `json:"key_encoding,omitempty"`
+	Value          any    `json:"value"`
+	ValueEncoding  string `json:"value_encoding,omitempty"`
+	DecodeError    string `json:"decode_error,omitempty"`
+	CreateRevision int64  `json:"create_revision,omitempty"`
+	ModRevision    int64  `json:"mod_revision,omitempty"`
 }
 
-func createRangeRequest(args []string) *regattapb.RangeRequest {
-	table := args[0]
-	if len(args) == 2 {
-		key := args[1]
-		if strings.HasSuffix(key, "*") {
-			key = strings.TrimSuffix(key, "*")
-			if len(key) == 0 {
-				// get all
-				return &regattapb.RangeRequest{
-					Table:    []byte(table),
-					Key:      zero,
-					RangeEnd: zero,
-					Limit:    rangeLimit,
-				}
-			}
-			// prefix search
-			return &regattapb.RangeRequest{
-				Table:    []byte(table),
-				Key:      []byte(key),
-				RangeEnd: []byte(findNextString(key)),
-				Limit:    rangeLimit,
-			}
-		}
-		// get by ID
-		return &regattapb.RangeRequest{
-			Table: []byte(table),
-			Key:   []byte(key),
-			Limit: rangeLimit,
-		}
+// newRangeCommandResult creates result from the given item, keys and values are encoded using selected output encodings,
+// or as Base64 strings when binary is set.
+func newRangeCommandResult(kv *regattapb.KeyValue, binary bool) rangeCommandResult {
+	keyEncoding, valueEncoding := outputKeyEncodingOption, outputValueEncodingOption
+	if binary {
+		keyEncoding, valueEncoding = outputEncodingType(base64Encoding), outputEncodingType(base64Encoding)
 	}
+	result := rangeCommandResult{CreateRevision: kv.CreateRevision, ModRevision: kv.ModRevision}
+	result.Key, result.KeyEncoding = keyEncoding.encode(kv.Key)
+	result.Value, result.ValueEncoding = valueEncoding.encode(kv.Value)
+	return result
+}
+
+// decodeValue replaces value with the decoded JSON document, when the value cannot be decoded, the error is recorded instead.
+func (r *rangeCommandResult) decodeValue(value []byte, decoder valueDecoder) {
+	decoded, err := decoder(value)
+	if err != nil {
+		r.DecodeError = err.Error()
+		return
+	}
+	r.Value = decoded
+	r.ValueEncoding = ""
+}
+
+func createRangeRequest(args []string) (*regattapb.RangeRequest, error) {
 	// get all
-	return &regattapb.RangeRequest{
-		Table:    []byte(table),
+	req := &regattapb.RangeRequest{
+		Table:    []byte(args[0]),
 		Key:      zero,
 		RangeEnd: zero,
 		Limit:    rangeLimit,
 	}
+	if len(args) == 2 {
+		// get by ID or prefix search
+		var err error
+		req.Key, req.RangeEnd, err = parseKeyRange(args[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// revisionFilter selects items by their revisions, zero bounds are not applied.
+type revisionFilter struct {
+	minMod    int64
+	maxMod    int64
+	minCreate int64
+	maxCreate int64
+}
+
+func newRevisionFilter() revisionFilter {
+	return revisionFilter{minMod: rangeMinModRevision, maxMod: rangeMaxModRevision, minCreate: rangeMinCreateRevision, maxCreate: rangeMaxCreateRevision}
 }
 
-func getValue(data []byte) string {
-	if rangeBinary {
-		return base64.StdEncoding.EncodeToString(data)
+func (f revisionFilter) enabled() bool {
+	return f != revisionFilter{}
+}
+
+func (f revisionFilter) matches(kv *regattapb.KeyValue) bool {
+	return (f.minMod == 0 || kv.ModRevision >= f.minMod) &&
+		(f.maxMod == 0 || kv.ModRevision <= f.maxMod) &&
+		(f.minCreate == 0 || kv.CreateRevision >= f.minCreate) &&
+		(f.maxCreate == 0 || kv.CreateRevision <= f.maxCreate)
+}
+
+// rangeFiltered retrieves items matching the range request and the revision filter. Without the filter, a single page is retrieved,
+// with the filter, pages of the given size are followed until the limit of the request is reached by matching items.
+func rangeFiltered(ctx context.Context, client regattapb.KVClient, req *regattapb.RangeRequest, filter revisionFilter, pageSize int64) ([]*regattapb.KeyValue, error) {
+	var kvs []*regattapb.KeyValue
+	if !filter.enabled() {
+		err := rangePages(ctx, client, req, func(page []*regattapb.KeyValue) bool {
+			kvs = page
+			return false
+		})
+		return kvs, err
 	}
-	return string(data)
+
+	limit := req.Limit
+	req.Limit = pageSize
+	err := rangePages(ctx, client, req, func(page []*regattapb.KeyValue) bool {
+		for _, kv := range page {
+			if !filter.matches(kv) {
+				continue
+			}
+			kvs = append(kvs, kv)
+			if limit > 0 && int64(len(kvs)) == limit {
+				return false
+			}
+		}
+		return true
+	})
+	return kvs, err
 }
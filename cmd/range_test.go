@@ -107,7 +107,261 @@ func Test_Range_Prefix(t *testing.T) {
 	assert.Equal(t, `[{"key":"test-key","value":"test-value"}]`, strings.TrimSpace(buf.String()))
 }
 
+func Test_Range_Revisions(t *testing.T) {
+	resetRangeFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("test-key")}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("test-key"), Value: []byte("test-value"), CreateRevision: 1, ModRevision: 5}}}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	buf := new(bytes.Buffer)
+	RootCmd.SetOut(buf)
+	RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "range", "table", "test-key"})
+	RootCmd.Execute()
+
+	assert.Equal(t, `[{"key":"test-key","value":"test-value","create_revision":1,"mod_revision":5}]`, strings.TrimSpace(buf.String()))
+}
+
+func Test_Range_Prefix_Hex(t *testing.T) {
+	resetRangeFlags()
+	defer func() { keyEncodingOption = utf8Encoding }()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte{0x01, 0xfe}, RangeEnd: []byte{0x01, 0xff}}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("test-key-1"), Value: []byte("test-value")}}}, nil)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte{0x01, 0xff}, RangeEnd: []byte{0x02}}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("test-key-2"), Value: []byte("test-value")}}}, nil)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte{0xff, 0xff}, RangeEnd: zero}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("test-key-3"), Value: []byte("test-value")}}}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	tests := []struct {
+		name   string
+		prefix string
+		want   string
+	}{
+		{
+			name:   "prefix",
+			prefix: "01fe*",
+			want:   `[{"key":"test-key-1","value":"test-value"}]`,
+		},
+		{
+			name:   "prefix ending with 0xff",
+			prefix: "01ff*",
+			want:   `[{"key":"test-key-2","value":"test-value"}]`,
+		},
+		{
+			name:   "prefix consisting of 0xff",
+			prefix: "ffff*",
+			want:   `[{"key":"test-key-3","value":"test-value"}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			RootCmd.SetOut(buf)
+			RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "--key-encoding", "hex", "range", "table", tt.prefix})
+			RootCmd.Execute()
+
+			assert.Equal(t, tt.want, strings.TrimSpace(buf.String()))
+		})
+	}
+}
+
+func Test_Range_OutputEncoding(t *testing.T) {
+	resetRangeFlags()
+	defer func() {
+		outputKeyEncodingOption = outputEncodingType(utf8Encoding)
+		outputValueEncodingOption = outputEncodingType(utf8Encoding)
+	}()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("test-key")}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("test-key"), Value: []byte{0x00, 0xff}}}}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	buf := new(bytes.Buffer)
+	RootCmd.SetOut(buf)
+	RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "--output-key-encoding", "hex", "--output-value-encoding", "auto", "range", "table", "test-key"})
+	RootCmd.Execute()
+
+	assert.Equal(t, `[{"key":"746573742d6b6579","value":"AP8=","value_encoding":"base64"}]`, strings.TrimSpace(buf.String()))
+}
+
+func Test_Range_Decode(t *testing.T) {
+	resetRangeFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: zero, RangeEnd: zero}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{
+			{Key: []byte("key1"), Value: []byte(`{"field": [1, 2]}`)},
+			{Key: []byte("key2"), Value: []byte("value")},
+		}}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	buf := new(bytes.Buffer)
+	RootCmd.SetOut(buf)
+	RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "range", "--decode", "json", "table"})
+	RootCmd.Execute()
+
+	assert.Equal(t, `[{"key":"key1","value":{"field":[1,2]}},{"key":"key2","value":"value","decode_error":"invalid JSON"}]`, strings.TrimSpace(buf.String()))
+}
+
+func Test_Range_ValueCodec(t *testing.T) {
+	resetRangeFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	codec := valueCodecType(gzipValueCodecName)
+	value, err := codec.compress([]byte(`{"field":1}`))
+	require.NoError(t, err)
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("test-key")}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("test-key"), Value: value}}}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	buf := new(bytes.Buffer)
+	RootCmd.SetOut(buf)
+	RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "range", "--value-codec", "auto", "--decode", "json", "table", "test-key"})
+	RootCmd.Execute()
+
+	assert.Equal(t, `[{"key":"test-key","value":{"field":1}}]`, strings.TrimSpace(buf.String()))
+}
+
+func Test_Range_Compress_Zstd(t *testing.T) {
+	resetRangeFlags()
+	// zstd compressor is registered globally by the client, so the test server supports it unlike Regatta v0.2.1
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("test-key")}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("test-key"), Value: []byte("test-value")}}}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	buf := new(bytes.Buffer)
+	RootCmd.SetOut(buf)
+	RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "range", "--compress", "zstd", "table", "test-key"})
+	RootCmd.Execute()
+
+	assert.Equal(t, `[{"key":"test-key","value":"test-value"}]`, strings.TrimSpace(buf.String()))
+}
+
+func Test_Range_Revision_Filters(t *testing.T) {
+	resetRangeFlags()
+	defer resetRangeFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	// filters are not sent to Regatta, which rejects them as unimplemented
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: zero, RangeEnd: zero, Limit: 2}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{
+			{Key: []byte("a"), Value: []byte("1"), CreateRevision: 1, ModRevision: 1},
+			{Key: []byte("b"), Value: []byte("2"), CreateRevision: 2, ModRevision: 5},
+		}, More: true}, nil)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("b\x00"), RangeEnd: zero, Limit: 2}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{
+			{Key: []byte("c"), Value: []byte("3"), CreateRevision: 3, ModRevision: 3},
+			{Key: []byte("d"), Value: []byte("4"), CreateRevision: 4, ModRevision: 6},
+		}}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "min mod revision",
+			args: []string{"--min-mod-revision", "5"},
+			want: `[{"key":"b","value":"2","create_revision":2,"mod_revision":5},{"key":"d","value":"4","create_revision":4,"mod_revision":6}]`,
+		},
+		{
+			name: "max create revision",
+			args: []string{"--max-create-revision", "1"},
+			want: `[{"key":"a","value":"1","create_revision":1,"mod_revision":1}]`,
+		},
+		{
+			name: "create and mod revision",
+			args: []string{"--min-create-revision", "2", "--max-mod-revision", "3"},
+			want: `[{"key":"c","value":"3","create_revision":3,"mod_revision":3}]`,
+		},
+		{
+			name: "limit of matching items",
+			args: []string{"--min-mod-revision", "5", "--limit", "1"},
+			want: `[{"key":"b","value":"2","create_revision":2,"mod_revision":5}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetRangeFlags()
+
+			buf := new(bytes.Buffer)
+			RootCmd.SetOut(buf)
+			RootCmd.SetArgs(append([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "range", "--page-size", "2", "table"}, tt.args...))
+			RootCmd.Execute()
+
+			assert.Equal(t, tt.want, strings.TrimSpace(buf.String()))
+		})
+	}
+}
+
 func resetRangeFlags() {
 	rangeLimit = 0
+	rangePageSize = 1000
 	rangeBinary = false
+	rangeMinModRevision = 0
+	rangeMaxModRevision = 0
+	rangeMinCreateRevision = 0
+	rangeMaxCreateRevision = 0
+	rangeDecode = noCodec
+	rangeProtoDescriptor = ""
+	rangeMessage = ""
+	rangeValueCodec = noValueCodec
+	compressOption = gzipCompress
 }
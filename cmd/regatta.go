@@ -1,9 +1,8 @@
 package cmd
 
 import (
-	"crypto/tls"
-	"crypto/x509"
-	"os"
+	"context"
+	"time"
 
 	"github.com/jamf/regatta/regattapb"
 	"github.com/spf13/cobra"
@@ -13,43 +12,143 @@ import (
 	"google.golang.org/grpc/status"
 )
 
+// sharedConn is a connection reused by all created clients, when set.
+var sharedConn *endpointsConn
+
 func createClient() (regattapb.KVClient, error) {
-	pool, err := x509.SystemCertPool()
+	if sharedConn != nil {
+		return regattapb.NewKVClient(sharedConn), nil
+	}
+	conn, err := dial()
 	if err != nil {
 		return nil, err
 	}
-	if len(certOption) != 0 {
-		certs, err := os.ReadFile(certOption)
+	return regattapb.NewKVClient(conn), nil
+}
+
+func dial() (*endpointsConn, error) {
+	endpoints, err := parseEndpoints(endpointOption)
+	if err != nil {
+		return nil, err
+	}
+	return dialEndpoints(context.Background(), endpoints)
+}
+
+// dialEndpoints creates connection to the given endpoints, additional dial options are applied to connections to all endpoints.
+func dialEndpoints(ctx context.Context, endpoints []string, opts ...grpc.DialOption) (*endpointsConn, error) {
+	tlsConfig, err := newTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	headers, err := parseHeaders(headersOption)
+	if err != nil {
+		return nil, err
+	}
+
+	interceptors := []grpc.UnaryClientInterceptor{
+		(&headersInterceptor{md: headers}).intercept,
+		(&compressor{compress: compressOption}).intercept,
+	}
+	conn := &endpointsConn{
+		balancing: balancingOption,
+		retrier:   &retrier{maxRetries: retriesOption, backoff: retryBackoff, maxBackoff: retryMaxBackoff, codes: retryCodesOption},
+	}
+	if verboseOption || debugOption {
+		conn.verbose = RootCmd.ErrOrStderr()
+		interceptors = append(interceptors, (&tracer{out: RootCmd.ErrOrStderr(), debug: debugOption}).intercept)
+	}
+
+	if telemetryExporter != noExporter {
+		if telemetry == nil {
+			if telemetry, err = setupTelemetry(telemetryExporter, RootCmd.ErrOrStderr()); err != nil {
+				return nil, err
+			}
+		}
+		instr, err := newInstrumentation(telemetry.tracerProvider, telemetry.meterProvider, telemetry.span.SpanContext())
 		if err != nil {
 			return nil, err
 		}
-		pool.AppendCertsFromPEM(certs)
+		interceptors = append([]grpc.UnaryClientInterceptor{instr.intercept}, interceptors...)
 	}
 
 	connOpts := []grpc.DialOption{
-		// nolint:gosec
-		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: pool, InsecureSkipVerify: insecureOption})),
+		grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)),
+		grpc.WithDefaultServiceConfig(balancingOption.serviceConfig()),
+		grpc.WithChainUnaryInterceptor(interceptors...),
+	}
+	if len(tokenOption) != 0 || len(tokenFileOption) != 0 {
+		connOpts = append(connOpts, grpc.WithPerRPCCredentials(&tokenCredentials{token: tokenOption, file: tokenFileOption}))
+	}
+	for _, endpoint := range endpoints {
+		target := endpointTarget(endpoint)
+		proxyURL, err := endpointProxy(endpoint)
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		if proxyURL != nil {
+			// the endpoint is resolved by the proxy
+			target = "passthrough:///" + endpoint
+		}
+		dialOpts := append([]grpc.DialOption{grpc.WithContextDialer(proxyContextDialer(proxyURL))}, connOpts...)
+		c, err := grpc.DialContext(ctx, target, append(dialOpts, opts...)...)
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		conn.conns = append(conn.conns, c)
 	}
+	return conn, nil
+}
+
+// rangePages retrieves items matching the range request page by page and calls the callback with items of each page,
+// subsequent pages are followed while Regatta indicates there are more items and the callback returns true.
+// Each page is retrieved with its own timeout, so that scanning a large range does not time out.
+func rangePages(ctx context.Context, client regattapb.KVClient, req *regattapb.RangeRequest, page func(kvs []*regattapb.KeyValue) bool) error {
+	for {
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		response, err := client.Range(timeoutCtx, req)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if !page(response.Kvs) || !response.More || len(response.Kvs) == 0 || len(req.RangeEnd) == 0 {
+			return nil
+		}
+		// continue right after the last retrieved key
+		req.Key = append(response.Kvs[len(response.Kvs)-1].Key, 0)
+	}
+}
 
-	conn, err := grpc.Dial(endpointOption, connOpts...)
+// rangeAll retrieves all items matching the range request, following subsequent pages when Regatta indicates there are more items.
+func rangeAll(ctx context.Context, client regattapb.KVClient, req *regattapb.RangeRequest) ([]*regattapb.KeyValue, error) {
+	var kvs []*regattapb.KeyValue
+	err := rangePages(ctx, client, req, func(page []*regattapb.KeyValue) bool {
+		kvs = append(kvs, page...)
+		return true
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	return regattapb.NewKVClient(conn), nil
+	return kvs, nil
 }
 
 func handleRegattaError(cmd *cobra.Command, err error) {
+	if isCompressorUnsupported(err) {
+		printErrorf(cmd, "Regatta does not support %q compression selected by --compress flag, use \"gzip\", \"snappy\", \"auto\" or \"none\" instead.\n", compressOption)
+		return
+	}
 	if st := status.Convert(err); st != nil {
 		switch st.Code() {
 		case codes.NotFound:
-			cmd.PrintErrln("The requested resource was not found:", st.Message())
+			printError(cmd, "The requested resource was not found:", st.Message())
 		case codes.Unavailable:
-			cmd.PrintErrln("Regatta is not reachable:", st.Message())
+			printError(cmd, "Regatta is not reachable:", st.Message())
 		default:
-			cmd.PrintErrf("Received RPC error from Regatta, code '%s' with message '%s'\n", st.Code(), st.Message())
+			printErrorf(cmd, "Received RPC error from Regatta, code '%s' with message '%s'\n", st.Code(), st.Message())
 		}
 	} else {
-		cmd.PrintErrln("There was an error, while querying Regatta.", err)
+		printError(cmd, "There was an error, while querying Regatta.", err)
 	}
 }
@@ -6,8 +6,13 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/jamf/regatta/regattapb"
+	"github.com/jamf/regatta/regattaserver"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/status"
 )
 
@@ -49,3 +54,50 @@ func Test_handleRegattaError(t *testing.T) {
 		})
 	}
 }
+
+func Test_Range_Network(t *testing.T) {
+	for _, network := range []string{"tcp", "unix"} {
+		t.Run(network, func(t *testing.T) {
+			resetRangeFlags()
+
+			lis, endpoint := listen(t, network)
+			s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+			storage := new(mockKVService)
+			storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("key")}).
+				Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("key"), Value: []byte("value")}}}, nil)
+
+			regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+			go s.Serve(lis)
+			defer s.Stop()
+
+			buf := new(bytes.Buffer)
+			RootCmd.SetOut(buf)
+			RootCmd.SetArgs([]string{"--endpoint", endpoint, "--cert", "test.crt", "range", "table", "key"})
+			RootCmd.Execute()
+
+			assert.Equal(t, `[{"key":"key","value":"value"}]`, strings.TrimSpace(buf.String()))
+		})
+	}
+}
+
+func Test_unixSocketAddress(t *testing.T) {
+	tests := []struct {
+		addr   string
+		want   string
+		wantOk bool
+	}{
+		{addr: "unix:///var/run/regatta.sock", want: "/var/run/regatta.sock", wantOk: true},
+		{addr: "unix:regatta.sock", want: "regatta.sock", wantOk: true},
+		{addr: "\x00regatta", want: "@regatta", wantOk: true},
+		{addr: "localhost:8443"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			socket, ok := unixSocketAddress(tt.addr)
+
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, socket)
+		})
+	}
+}
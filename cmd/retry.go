@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/jamf/regatta/regattapb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// codesType is a list of gRPC status codes, codes are provided by their names, e.g. "Unavailable".
+type codesType []codes.Code
+
+func (c *codesType) String() string {
+	names := make([]string, 0, len(*c))
+	for _, code := range *c {
+		names = append(names, code.String())
+	}
+	return strings.Join(names, ",")
+}
+
+func (c *codesType) Set(v string) error {
+	var parsed codesType
+	for _, name := range strings.Split(v, ",") {
+		code, ok := parseCode(strings.TrimSpace(name))
+		if !ok {
+			return fmt.Errorf("unknown gRPC status code %q", name)
+		}
+		parsed = append(parsed, code)
+	}
+	*c = parsed
+	return nil
+}
+
+func (c *codesType) Type() string {
+	return "codes"
+}
+
+// contains reports whether the list contains the given code.
+func (c codesType) contains(code codes.Code) bool {
+	for _, cc := range c {
+		if cc == code {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCode parses gRPC status code from its name, names are matched case-insensitively and underscores are ignored,
+// so that both "ResourceExhausted" and "RESOURCE_EXHAUSTED" are accepted.
+func parseCode(name string) (codes.Code, bool) {
+	name = strings.ReplaceAll(name, "_", "")
+	for code := codes.OK; code <= codes.Unauthenticated; code++ {
+		if strings.EqualFold(code.String(), name) {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+// retrier retries calls failed with one of the retryable codes, using exponential backoff with jitter between attempts.
+// Only idempotent calls are retried, transactions are retried only when they do not modify any data.
+type retrier struct {
+	maxRetries int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	codes      codesType
+}
+
+// noRetryCallOption disables retries of the call.
+type noRetryCallOption struct {
+	grpc.EmptyCallOption
+}
+
+// hasNoRetry reports whether retries of the call are disabled by its options.
+func hasNoRetry(opts []grpc.CallOption) bool {
+	for _, opt := range opts {
+		if _, ok := opt.(noRetryCallOption); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// invoke makes the call of the request and retries it, when it fails with one of the retryable codes.
+func (r *retrier) invoke(ctx context.Context, req any, call func() error) error {
+	err := call()
+	if !isIdempotent(req) {
+		return err
+	}
+	for retry := 0; retry < r.maxRetries && r.codes.contains(status.Code(err)); retry++ {
+		timer := time.NewTimer(r.delay(retry))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		err = call()
+	}
+	return err
+}
+
+// delay returns a delay before the given retry, the delay grows exponentially up to the maximal backoff
+// and is randomized to the range [delay/2, delay), so that clients do not retry in lockstep.
+func (r *retrier) delay(retry int) time.Duration {
+	delay := r.maxBackoff
+	if retry < 32 && r.backoff<<retry < r.maxBackoff {
+		delay = r.backoff << retry
+	}
+	if delay <= 1 {
+		return delay
+	}
+	// nolint:gosec
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
+}
+
+// isIdempotent reports whether the request can be safely sent to Regatta repeatedly.
+func isIdempotent(req any) bool {
+	txn, ok := req.(*regattapb.TxnRequest)
+	if !ok {
+		return true
+	}
+	return isReadOnly(txn.GetSuccess()) && isReadOnly(txn.GetFailure())
+}
+
+func isReadOnly(ops []*regattapb.RequestOp) bool {
+	for _, op := range ops {
+		if op.GetRequestRange() == nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jamf/regatta/regattapb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func Test_retrier(t *testing.T) {
+	tests := []struct {
+		name         string
+		req          any
+		errs         []error
+		wantAttempts int
+		wantCode     codes.Code
+	}{
+		{
+			name:         "retried until success",
+			req:          &regattapb.RangeRequest{},
+			errs:         []error{status.Error(codes.Unavailable, "leader election"), status.Error(codes.ResourceExhausted, "busy"), nil},
+			wantAttempts: 3,
+			wantCode:     codes.OK,
+		},
+		{
+			name: "retries exhausted",
+			req:  &regattapb.PutRequest{},
+			errs: []error{
+				status.Error(codes.Unavailable, "leader election"),
+				status.Error(codes.Unavailable, "leader election"),
+				status.Error(codes.Unavailable, "leader election"),
+				status.Error(codes.Unavailable, "leader election"),
+			},
+			wantAttempts: 4,
+			wantCode:     codes.Unavailable,
+		},
+		{
+			name:         "error not retryable",
+			req:          &regattapb.DeleteRangeRequest{},
+			errs:         []error{status.Error(codes.Internal, "internal error"), nil},
+			wantAttempts: 1,
+			wantCode:     codes.Internal,
+		},
+		{
+			name: "read-only transaction",
+			req: &regattapb.TxnRequest{
+				Success: []*regattapb.RequestOp{{Request: &regattapb.RequestOp_RequestRange{RequestRange: &regattapb.RequestOp_Range{}}}},
+			},
+			errs:         []error{status.Error(codes.Unavailable, "leader election"), nil},
+			wantAttempts: 2,
+			wantCode:     codes.OK,
+		},
+		{
+			name: "transaction modifying data",
+			req: &regattapb.TxnRequest{
+				Success: []*regattapb.RequestOp{{Request: &regattapb.RequestOp_RequestRange{RequestRange: &regattapb.RequestOp_Range{}}}},
+				Failure: []*regattapb.RequestOp{{Request: &regattapb.RequestOp_RequestPut{RequestPut: &regattapb.RequestOp_Put{}}}},
+			},
+			errs:         []error{status.Error(codes.Unavailable, "leader election"), nil},
+			wantAttempts: 1,
+			wantCode:     codes.Unavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &retrier{maxRetries: 3, backoff: time.Millisecond, maxBackoff: 2 * time.Millisecond, codes: codesType{codes.Unavailable, codes.ResourceExhausted}}
+			attempts := 0
+			call := func() error {
+				err := tt.errs[attempts]
+				attempts++
+				return err
+			}
+
+			err := r.invoke(context.Background(), tt.req, call)
+
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantAttempts, attempts)
+		})
+	}
+}
+
+func Test_retrier_delay(t *testing.T) {
+	r := &retrier{backoff: 100 * time.Millisecond, maxBackoff: time.Second}
+
+	for retry, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		delay := r.delay(retry)
+		assert.GreaterOrEqual(t, delay, want/2)
+		assert.Less(t, delay, want)
+	}
+	assert.GreaterOrEqual(t, r.delay(100), time.Second/2)
+}
+
+func Test_codesType_Set(t *testing.T) {
+	var c codesType
+
+	require.NoError(t, c.Set("Unavailable,RESOURCE_EXHAUSTED, aborted"))
+	assert.Equal(t, codesType{codes.Unavailable, codes.ResourceExhausted, codes.Aborted}, c)
+	assert.Equal(t, "Unavailable,ResourceExhausted,Aborted", c.String())
+	require.Error(t, c.Set("Unavailable,Unknown code"))
+}
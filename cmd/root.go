@@ -2,8 +2,10 @@ package cmd
 
 import (
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
 )
 
 // Version is set during release of project.
@@ -16,28 +18,118 @@ var RootCmd = cobra.Command{
 	Long: "Command-line tool wrapping API calls to Regatta (https://engineering.jamf.com/regatta/).\n" +
 		"Simplifies querying for data in Regatta store and other operations.",
 	Version: Version,
+	PersistentPreRun: func(cmd *cobra.Command, _ []string) {
+		commandPath = cmd.CommandPath()
+	},
 }
 
 var (
-	endpointOption string
-	insecureOption bool
-	certOption     string
+	endpointOption    string
+	insecureOption    bool
+	certOption        string
+	caDirOption       string
+	noSystemCAOption  bool
+	pinsOption        []string
+	verboseOption     bool
+	debugOption       bool
+	telemetryExporter = noExporter
+	headersOption     []string
+	tokenOption       string
+	tokenFileOption   string
+	proxyOption       string
+	balancingOption   = pickFirstBalancing
+	retriesOption     int
+	retryBackoff      time.Duration
+	retryMaxBackoff   time.Duration
+	retryCodesOption  = codesType{codes.Unavailable, codes.ResourceExhausted}
+	compressOption    = gzipCompress
+	keyEncodingOption = utf8Encoding
+
+	outputKeyEncodingOption   = outputEncodingType(utf8Encoding)
+	outputValueEncodingOption = outputEncodingType(utf8Encoding)
+
+	// commandPath is a path of the executed command.
+	commandPath = "regatta-client"
 )
 
 func init() {
-	RootCmd.PersistentFlags().StringVar(&endpointOption, "endpoint", "localhost:8443", "regatta API endpoint")
+	RootCmd.PersistentFlags().StringVar(&endpointOption, "endpoint", "localhost:8443", "regatta API endpoint, multiple endpoints of the cluster can be provided as comma-separated list, "+
+		"Unix domain socket can be provided as unix:///path/to/regatta.sock")
 	RootCmd.PersistentFlags().BoolVar(&insecureOption, "insecure", false, "allow insecure connection, controls whether certificates are validated")
 	RootCmd.PersistentFlags().StringVar(&certOption, "cert", "", "regatta CA cert")
+	RootCmd.PersistentFlags().StringVar(&caDirOption, "ca-dir", "", "directory containing trusted CA certs, files with .pem, .crt and .cer extensions are loaded")
+	RootCmd.PersistentFlags().BoolVar(&noSystemCAOption, "no-system-ca", false, "do not trust system CA certs, only CA certs provided by --cert and --ca-dir flags are trusted")
+	RootCmd.PersistentFlags().StringArrayVar(&pinsOption, "pin-sha256", nil, "Base64 encoded SHA-256 hash of public key (SPKI), which must be present in the server certificate chain, "+
+		"can be provided multiple times")
+	RootCmd.PersistentFlags().Var(&keyEncodingOption, "key-encoding", `encoding of provided keys, allowed values: "utf8", "base64", "hex" and "escaped"`)
+	RootCmd.RegisterFlagCompletionFunc("key-encoding", encodingTypeCompletion)
+	RootCmd.PersistentFlags().Var(&outputKeyEncodingOption, "output-key-encoding", `encoding of printed keys, allowed values: "utf8", "base64", "hex", "escaped" and "auto"`)
+	RootCmd.RegisterFlagCompletionFunc("output-key-encoding", outputEncodingTypeCompletion)
+	RootCmd.PersistentFlags().Var(&outputValueEncodingOption, "output-value-encoding", `encoding of printed values, allowed values: "utf8", "base64", "hex", "escaped" and "auto"`)
+	RootCmd.RegisterFlagCompletionFunc("output-value-encoding", outputEncodingTypeCompletion)
+	RootCmd.PersistentFlags().Var(&compressOption, "compress", `use compression for all requests, allowed values: "gzip", "snappy", "zstd", "lz4", "auto" and "none", "zstd" and "lz4" require support in Regatta, which is missing in Regatta v0.2.1`)
+	RootCmd.RegisterFlagCompletionFunc("compress", compressTypeCompletion)
+	RootCmd.PersistentFlags().Var(&balancingOption, "load-balancing", `load balancing of requests across endpoints, allowed values: "pick-first" and "round-robin"`)
+	RootCmd.RegisterFlagCompletionFunc("load-balancing", balancingTypeCompletion)
+	RootCmd.PersistentFlags().IntVar(&retriesOption, "retries", 3, "maximum number of retries of requests failed with transient errors, 0 disables retries")
+	RootCmd.PersistentFlags().DurationVar(&retryBackoff, "retry-backoff", 100*time.Millisecond, "initial backoff between retries, the backoff is doubled with each retry")
+	RootCmd.PersistentFlags().DurationVar(&retryMaxBackoff, "retry-max-backoff", 2*time.Second, "maximal backoff between retries")
+	RootCmd.PersistentFlags().Var(&retryCodesOption, "retry-codes", "comma-separated list of gRPC status codes of transient errors, which are retried")
+	RootCmd.PersistentFlags().BoolVarP(&verboseOption, "verbose", "v", false, "print sent requests together with endpoint, compression, sizes, latency and Regatta response header of each request to standard error")
+	RootCmd.PersistentFlags().BoolVar(&debugOption, "debug", false, "print also gRPC metadata with redacted credentials and whole responses to standard error, implies --verbose")
+	RootCmd.PersistentFlags().Var(&telemetryExporter, "otel-exporter", `export OpenTelemetry traces and metrics of requests, allowed values: "otlp", "stdout" and "none"`)
+	RootCmd.RegisterFlagCompletionFunc("otel-exporter", exporterTypeCompletion)
+	RootCmd.PersistentFlags().StringArrayVar(&headersOption, "header", nil, "header sent with each request in key=value format, can be provided multiple times")
+	RootCmd.PersistentFlags().StringVar(&tokenOption, "token", "", "bearer token sent with each request")
+	RootCmd.PersistentFlags().StringVar(&tokenFileOption, "token-file", "", "file containing bearer token sent with each request, the file is read for each request, so that the token can be refreshed")
+	RootCmd.MarkFlagsMutuallyExclusive("token", "token-file")
+	RootCmd.PersistentFlags().StringVar(&proxyOption, "proxy", "", "URL of HTTP CONNECT (http://, https://) or SOCKS5 (socks5://) proxy, "+
+		"by default the proxy is taken from HTTPS_PROXY or ALL_PROXY environment variables respecting NO_PROXY")
 
 	RootCmd.AddCommand(&Range)
 	RootCmd.AddCommand(&Delete)
 	RootCmd.AddCommand(&Put)
+	RootCmd.AddCommand(&Watch)
+	RootCmd.AddCommand(&Bench)
+	RootCmd.AddCommand(&Exec)
+	RootCmd.AddCommand(&TLS)
+	RootCmd.AddCommand(&Ping)
+	RootCmd.AddCommand(&Stats)
+	RootCmd.AddCommand(&Ls)
 	RootCmd.AddCommand(&Man)
 
 	RootCmd.SetOut(os.Stdout)
 }
 
-// Execute executes root command of regatta-client.
+// exitStatus is the exit status of the executed command, commands set it when they fail.
+var exitStatus int
+
+// printError prints the error and marks the executed command as failed.
+func printError(cmd *cobra.Command, i ...any) {
+	exitStatus = 1
+	cmd.PrintErrln(i...)
+}
+
+// printErrorf prints the formatted error and marks the executed command as failed.
+func printErrorf(cmd *cobra.Command, format string, i ...any) {
+	exitStatus = 1
+	cmd.PrintErrf(format, i...)
+}
+
+// executeCommand executes root command and returns its exit status.
+func executeCommand() int {
+	exitStatus = 0
+	if err := RootCmd.Execute(); err != nil {
+		return 1
+	}
+	return exitStatus
+}
+
+// Execute executes root command of regatta-client and exits with non-zero status, when the command fails.
 func Execute() {
-	_ = RootCmd.Execute()
+	status := executeCommand()
+	shutdownTelemetry()
+	if status != 0 {
+		os.Exit(status)
+	}
 }
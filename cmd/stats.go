@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/signal"
+	"sort"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+	"github.com/jamf/regatta/regattapb"
+	"github.com/spf13/cobra"
+)
+
+// maxTrackedSize is the highest size of key or value tracked by size histograms, larger sizes are tracked as this size.
+const maxTrackedSize = 1 << 32
+
+var (
+	statsKeysOnly  bool
+	statsPageSize  int64
+	statsTop       int
+	statsDelimiter string
+	statsDepth     int
+)
+
+func init() {
+	Stats.Flags().BoolVar(&statsKeysOnly, "keys-only", false, "retrieve only keys, value sizes are not reported")
+	Stats.Flags().Int64Var(&statsPageSize, "page-size", 1000, "number of items retrieved by a single request")
+	Stats.Flags().IntVar(&statsTop, "top", 10, "number of largest keys reported")
+	Stats.Flags().StringVar(&statsDelimiter, "delimiter", "/", "separator of key segments used for histogram of key prefixes, empty disables the histogram")
+	Stats.Flags().IntVar(&statsDepth, "depth", 1, "number of key segments forming prefixes in histogram of key prefixes")
+}
+
+// Stats is a subcommand used for computing statistics of keys and values in a table.
+var Stats = cobra.Command{
+	Use:   "stats <table> [prefix*]",
+	Short: "Compute statistics of keys and values in Regatta store",
+	Long: "Scans all items of the table, or all items with the given prefix, using paginated Range queries as defined in API (https://engineering.jamf.com/regatta/api/#range) " +
+		"and prints statistics of the items as JSON object.\n" +
+		"The statistics contain number of keys, total, minimal, mean, maximal and percentile sizes of keys and values in bytes, " +
+		"the largest items and a histogram of key prefixes. With --keys-only flag, values are not retrieved, " +
+		"which makes the scan cheaper, but value sizes are not reported and the largest items are ordered by key size.\n" +
+		"Prefixes in the histogram consist of the first key segments separated by --delimiter, up to the number of segments given by --depth flag, " +
+		"keys with fewer segments are counted under the prefix ending with their last delimiter.\n" +
+		"When prefix is provided, it needs to be valid UTF-8 string, unless different encoding is selected using --key-encoding flag. " +
+		"Percentiles are approximate, with precision of 3 significant digits.",
+	Example: "regatta-client stats table\n" +
+		"regatta-client stats --keys-only table 'tenant/*'\n" +
+		"regatta-client stats --delimiter / --depth 2 table",
+	Args: cobra.MatchAll(cobra.MinimumNArgs(1), cobra.MaximumNArgs(2)),
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := createClient()
+		if err != nil {
+			printError(cmd, "There was an error, while establishing connection to Regatta.", err)
+			return
+		}
+
+		req := &regattapb.RangeRequest{Table: []byte(args[0]), Key: zero, RangeEnd: zero, Limit: statsPageSize, KeysOnly: statsKeysOnly}
+		if len(args) == 2 {
+			if req.Key, req.RangeEnd, err = parseKeyRange(args[1]); err != nil {
+				printError(cmd, "There was an error while decoding parameters.", err)
+				return
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		stats := newKeyStats(statsKeysOnly, statsTop, []byte(statsDelimiter), statsDepth)
+		err = rangePages(ctx, client, req, func(page []*regattapb.KeyValue) bool {
+			for _, kv := range page {
+				stats.add(kv)
+			}
+			return true
+		})
+		if err != nil {
+			handleRegattaError(cmd, err)
+			return
+		}
+
+		marshal, _ := json.Marshal(stats.report())
+		cmd.Println(string(marshal))
+	},
+}
+
+// keyStats accumulates statistics of scanned items.
+type keyStats struct {
+	keysOnly  bool
+	top       int
+	delimiter []byte
+	depth     int
+
+	keys     int64
+	keySizes *sizeStats
+	valSizes *sizeStats
+	largest  []largestEntry
+	prefixes map[string]*prefixStats
+}
+
+func newKeyStats(keysOnly bool, top int, delimiter []byte, depth int) *keyStats {
+	return &keyStats{
+		keysOnly:  keysOnly,
+		top:       top,
+		delimiter: delimiter,
+		depth:     depth,
+		keySizes:  newSizeStats(),
+		valSizes:  newSizeStats(),
+		prefixes:  make(map[string]*prefixStats),
+	}
+}
+
+func (s *keyStats) add(kv *regattapb.KeyValue) {
+	s.keys++
+	s.keySizes.add(len(kv.Key))
+	if !s.keysOnly {
+		s.valSizes.add(len(kv.Value))
+	}
+	s.addLargest(kv)
+
+	if len(s.delimiter) == 0 || s.depth <= 0 {
+		return
+	}
+	prefix := string(keyPrefix(kv.Key, s.delimiter, s.depth))
+	p, ok := s.prefixes[prefix]
+	if !ok {
+		p = &prefixStats{}
+		s.prefixes[prefix] = p
+	}
+	p.keys++
+	p.size += int64(len(kv.Key) + len(kv.Value))
+}
+
+// addLargest keeps the largest items sorted by their size in descending order.
+func (s *keyStats) addLargest(kv *regattapb.KeyValue) {
+	if s.top <= 0 {
+		return
+	}
+	item := largestEntry{key: kv.Key, valueSize: len(kv.Value)}
+	if len(s.largest) == s.top && item.size() <= s.largest[len(s.largest)-1].size() {
+		return
+	}
+	i := sort.Search(len(s.largest), func(i int) bool { return s.largest[i].size() < item.size() })
+	s.largest = append(s.largest, largestEntry{})
+	copy(s.largest[i+1:], s.largest[i:])
+	s.largest[i] = item
+	if len(s.largest) > s.top {
+		s.largest = s.largest[:s.top]
+	}
+}
+
+// largestEntry is one of the largest items, values are not kept, only their sizes.
+type largestEntry struct {
+	key       []byte
+	valueSize int
+}
+
+func (e largestEntry) size() int {
+	return len(e.key) + e.valueSize
+}
+
+// keyPrefix returns the first depth segments of the key including the trailing delimiter,
+// keys with fewer segments are cut after their last delimiter.
+func keyPrefix(key, delimiter []byte, depth int) []byte {
+	end := 0
+	for i := 0; i < depth; i++ {
+		idx := bytes.Index(key[end:], delimiter)
+		if idx < 0 {
+			break
+		}
+		end += idx + len(delimiter)
+	}
+	return key[:end]
+}
+
+type prefixStats struct {
+	keys int64
+	size int64
+}
+
+// sizeStats accumulates sizes in bytes, total, minimal, maximal and mean sizes are exact, percentiles are approximated by the histogram.
+type sizeStats struct {
+	count     int64
+	total     int64
+	min       int64
+	max       int64
+	histogram *hdrhistogram.Histogram
+}
+
+func newSizeStats() *sizeStats {
+	return &sizeStats{histogram: hdrhistogram.New(1, maxTrackedSize, 3)}
+}
+
+func (s *sizeStats) add(size int) {
+	if s.count == 0 || int64(size) < s.min {
+		s.min = int64(size)
+	}
+	s.max = max(s.max, int64(size))
+	s.count++
+	s.total += int64(size)
+	_ = s.histogram.RecordValue(min(int64(size), maxTrackedSize))
+}
+
+type statsReport struct {
+	Keys      int64             `json:"keys"`
+	TotalSize int64             `json:"total_size"`
+	KeySize   sizeReport        `json:"key_size"`
+	ValueSize *sizeReport       `json:"value_size,omitempty"`
+	Largest   []largestItem     `json:"largest"`
+	Prefixes  []prefixHistogram `json:"prefixes,omitempty"`
+}
+
+type sizeReport struct {
+	Total int64   `json:"total"`
+	Min   int64   `json:"min"`
+	Mean  float64 `json:"mean"`
+	P50   int64   `json:"p50"`
+	P90   int64   `json:"p90"`
+	P99   int64   `json:"p99"`
+	Max   int64   `json:"max"`
+}
+
+type largestItem struct {
+	Key         string `json:"key"`
+	KeyEncoding string `json:"key_encoding,omitempty"`
+	KeySize     int    `json:"key_size"`
+	ValueSize   *int   `json:"value_size,omitempty"`
+}
+
+type prefixHistogram struct {
+	Prefix         string `json:"prefix"`
+	PrefixEncoding string `json:"prefix_encoding,omitempty"`
+	Keys           int64  `json:"keys"`
+	Size           int64  `json:"size"`
+}
+
+func (s *sizeStats) report() sizeReport {
+	report := sizeReport{
+		Total: s.total,
+		Min:   s.min,
+		P50:   min(s.histogram.ValueAtQuantile(50), s.max),
+		P90:   min(s.histogram.ValueAtQuantile(90), s.max),
+		P99:   min(s.histogram.ValueAtQuantile(99), s.max),
+		Max:   s.max,
+	}
+	if s.count != 0 {
+		report.Mean = float64(s.total) / float64(s.count)
+	}
+	return report
+}
+
+func (s *keyStats) report() statsReport {
+	report := statsReport{
+		Keys:      s.keys,
+		TotalSize: s.keySizes.total + s.valSizes.total,
+		KeySize:   s.keySizes.report(),
+		Largest:   make([]largestItem, 0, len(s.largest)),
+	}
+	if !s.keysOnly {
+		valueSize := s.valSizes.report()
+		report.ValueSize = &valueSize
+	}
+	for _, entry := range s.largest {
+		item := largestItem{KeySize: len(entry.key)}
+		item.Key, item.KeyEncoding = outputKeyEncodingOption.encode(entry.key)
+		if !s.keysOnly {
+			valueSize := entry.valueSize
+			item.ValueSize = &valueSize
+		}
+		report.Largest = append(report.Largest, item)
+	}
+	prefixes := make([]string, 0, len(s.prefixes))
+	for prefix := range s.prefixes {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		p := s.prefixes[prefix]
+		h := prefixHistogram{Keys: p.keys, Size: p.size}
+		h.Prefix, h.PrefixEncoding = outputKeyEncodingOption.encode([]byte(prefix))
+		report.Prefixes = append(report.Prefixes, h)
+	}
+	return report
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jamf/regatta/regattapb"
+	"github.com/jamf/regatta/regattaserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+func Test_Stats(t *testing.T) {
+	defer resetStatsFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: zero, RangeEnd: zero, Limit: 2}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{
+			{Key: []byte("a/1"), Value: []byte("abc")},
+			{Key: []byte("a/2"), Value: []byte("0123456789")},
+		}, More: true}, nil)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("a/2\x00"), RangeEnd: zero, Limit: 2}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{
+			{Key: []byte("b/x/1"), Value: []byte("v")},
+			{Key: []byte("c"), Value: []byte{}},
+		}}, nil)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("a/"), RangeEnd: []byte("a0"), Limit: 2, KeysOnly: true}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{
+			{Key: []byte("a/1")},
+			{Key: []byte("a/22")},
+		}}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "all items",
+			args: []string{"table", "--top", "2"},
+			want: `{"keys":4,"total_size":26,"key_size":{"total":12,"min":1,"mean":3,"p50":3,"p90":5,"p99":5,"max":5},` +
+				`"value_size":{"total":14,"min":0,"mean":3.5,"p50":1,"p90":10,"p99":10,"max":10},` +
+				`"largest":[{"key":"a/2","key_size":3,"value_size":10},{"key":"a/1","key_size":3,"value_size":3}],` +
+				`"prefixes":[{"prefix":"","keys":1,"size":1},{"prefix":"a/","keys":2,"size":19},{"prefix":"b/","keys":1,"size":6}]}`,
+		},
+		{
+			name: "all items with deeper prefixes",
+			args: []string{"table", "--top", "0", "--depth", "2"},
+			want: `{"keys":4,"total_size":26,"key_size":{"total":12,"min":1,"mean":3,"p50":3,"p90":5,"p99":5,"max":5},` +
+				`"value_size":{"total":14,"min":0,"mean":3.5,"p50":1,"p90":10,"p99":10,"max":10},"largest":[],` +
+				`"prefixes":[{"prefix":"","keys":1,"size":1},{"prefix":"a/","keys":2,"size":19},{"prefix":"b/x/","keys":1,"size":6}]}`,
+		},
+		{
+			name: "keys with prefix",
+			args: []string{"table", "a/*", "--keys-only", "--delimiter", ""},
+			want: `{"keys":2,"total_size":7,"key_size":{"total":7,"min":3,"mean":3.5,"p50":3,"p90":4,"p99":4,"max":4},` +
+				`"largest":[{"key":"a/22","key_size":4},{"key":"a/1","key_size":3}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetStatsFlags()
+
+			buf := new(bytes.Buffer)
+			RootCmd.SetOut(buf)
+			RootCmd.SetArgs(append([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "stats", "--page-size", "2"}, tt.args...))
+			RootCmd.Execute()
+
+			assert.JSONEq(t, tt.want, strings.TrimSpace(buf.String()))
+		})
+	}
+}
+
+func Test_keyPrefix(t *testing.T) {
+	tests := []struct {
+		key   string
+		depth int
+		want  string
+	}{
+		{key: "tenant/1/device/2", depth: 1, want: "tenant/"},
+		{key: "tenant/1/device/2", depth: 3, want: "tenant/1/device/"},
+		{key: "tenant/1", depth: 3, want: "tenant/"},
+		{key: "tenant", depth: 1, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			assert.Equal(t, tt.want, string(keyPrefix([]byte(tt.key), []byte("/"), tt.depth)))
+		})
+	}
+}
+
+func resetStatsFlags() {
+	statsKeysOnly = false
+	statsPageSize = 1000
+	statsTop = 10
+	statsDelimiter = "/"
+	statsDepth = 1
+}
@@ -0,0 +1,255 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jamf/regatta/regattapb"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	noExporter     = exporterType("none")
+	otlpExporter   = exporterType("otlp")
+	stdoutExporter = exporterType("stdout")
+
+	instrumentationName = "github.com/tantalor93/regatta-client"
+)
+
+type exporterType string
+
+func (e *exporterType) String() string {
+	return string(*e)
+}
+
+func (e *exporterType) Set(v string) error {
+	switch exporterType(v) {
+	case noExporter, otlpExporter, stdoutExporter:
+		*e = exporterType(v)
+		return nil
+	default:
+		return errors.New(`must be one of "otlp", "stdout" or "none"`)
+	}
+}
+
+func (e *exporterType) Type() string {
+	return "exporterType"
+}
+
+func exporterTypeCompletion(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	return []string{
+		"otlp\texport traces and metrics using OTLP, configured by OTEL_EXPORTER_OTLP_* environment variables",
+		"stdout\tprint traces and metrics as JSON to standard error",
+		"none\tdisable OpenTelemetry instrumentation",
+	}, cobra.ShellCompDirectiveDefault
+}
+
+// telemetry holds OpenTelemetry providers set up for the command, together with the span covering the whole command.
+var telemetry *telemetryProviders
+
+type telemetryProviders struct {
+	tracerProvider *sdktrace.TracerProvider
+	meterProvider  *sdkmetric.MeterProvider
+	ctx            context.Context
+	span           trace.Span
+}
+
+// setupTelemetry sets up OpenTelemetry providers exporting using the selected exporter, the span covering the whole command
+// is started as a child of the trace context provided in TRACEPARENT and TRACESTATE environment variables, if any.
+func setupTelemetry(exporter exporterType, out io.Writer) (*telemetryProviders, error) {
+	ctx := context.Background()
+	res, err := resource.New(ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithAttributes(semconv.ServiceName("regatta-client"), semconv.ServiceVersion(Version)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var spanExporter sdktrace.SpanExporter
+	var metricExporter sdkmetric.Exporter
+	switch exporter {
+	case otlpExporter:
+		if spanExporter, err = otlptracegrpc.New(ctx); err != nil {
+			return nil, err
+		}
+		if metricExporter, err = otlpmetricgrpc.New(ctx); err != nil {
+			return nil, err
+		}
+	default:
+		if spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(out)); err != nil {
+			return nil, err
+		}
+		if metricExporter, err = stdoutmetric.New(stdoutmetric.WithEncoder(json.NewEncoder(out))); err != nil {
+			return nil, err
+		}
+	}
+
+	t := &telemetryProviders{
+		tracerProvider: sdktrace.NewTracerProvider(sdktrace.WithBatcher(spanExporter), sdktrace.WithResource(res)),
+		meterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)), sdkmetric.WithResource(res)),
+	}
+	parent := propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{
+		"traceparent": os.Getenv("TRACEPARENT"),
+		"tracestate":  os.Getenv("TRACESTATE"),
+	})
+	t.ctx, t.span = t.tracerProvider.Tracer(instrumentationName).Start(parent, commandPath)
+	return t, nil
+}
+
+// shutdown ends the span covering the whole command and flushes all recorded telemetry.
+func (t *telemetryProviders) shutdown(ctx context.Context) error {
+	t.span.End()
+	return errors.Join(t.tracerProvider.Shutdown(ctx), t.meterProvider.Shutdown(ctx))
+}
+
+// shutdownTelemetry flushes telemetry recorded by the command, if it was set up.
+func shutdownTelemetry() {
+	if telemetry == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := telemetry.shutdown(ctx); err != nil {
+		RootCmd.PrintErrln("There was an error while exporting telemetry.", err)
+	}
+	telemetry = nil
+}
+
+// instrumentation records span and metrics for each call and propagates the trace context to Regatta.
+// Calls made without trace context are recorded as children of the parent span.
+type instrumentation struct {
+	tracer       trace.Tracer
+	parent       trace.SpanContext
+	duration     metric.Float64Histogram
+	requestSize  metric.Int64Histogram
+	responseSize metric.Int64Histogram
+}
+
+func newInstrumentation(tp trace.TracerProvider, mp metric.MeterProvider, parent trace.SpanContext) (*instrumentation, error) {
+	meter := mp.Meter(instrumentationName)
+	duration, err := meter.Float64Histogram("rpc.client.duration", metric.WithUnit("ms"),
+		metric.WithDescription("duration of requests sent to Regatta"))
+	if err != nil {
+		return nil, err
+	}
+	requestSize, err := meter.Int64Histogram("rpc.client.request.size", metric.WithUnit("By"),
+		metric.WithDescription("size of requests sent to Regatta"))
+	if err != nil {
+		return nil, err
+	}
+	responseSize, err := meter.Int64Histogram("rpc.client.response.size", metric.WithUnit("By"),
+		metric.WithDescription("size of responses received from Regatta"))
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentation{
+		tracer:       tp.Tracer(instrumentationName),
+		parent:       parent,
+		duration:     duration,
+		requestSize:  requestSize,
+		responseSize: responseSize,
+	}, nil
+}
+
+func (i *instrumentation) intercept(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if !trace.SpanContextFromContext(ctx).IsValid() && i.parent.IsValid() {
+		ctx = trace.ContextWithSpanContext(ctx, i.parent)
+	}
+	service, name := path.Split(strings.TrimPrefix(method, "/"))
+	attrs := []attribute.KeyValue{semconv.RPCSystemGRPC, semconv.RPCService(strings.TrimSuffix(service, "/")), semconv.RPCMethod(name)}
+	if t, ok := req.(interface{ GetTable() []byte }); ok {
+		attrs = append(attrs, attribute.String("regatta.table", string(t.GetTable())))
+	}
+
+	ctx, span := i.tracer.Start(ctx, strings.TrimPrefix(method, "/"), trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	defer span.End()
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	propagation.TraceContext{}.Inject(ctx, metadataCarrier(md))
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	elapsed := time.Since(start)
+
+	code := status.Code(err)
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+	if err != nil {
+		span.SetStatus(otelcodes.Error, status.Convert(err).Message())
+	} else if count, ok := keyCount(reply); ok {
+		span.SetAttributes(attribute.Int64("regatta.key_count", count))
+	}
+
+	measured := metric.WithAttributes(append(attrs, semconv.RPCGRPCStatusCodeKey.Int(int(code)))...)
+	i.duration.Record(ctx, float64(elapsed)/float64(time.Millisecond), measured)
+	i.requestSize.Record(ctx, int64(messageSize(req)), measured)
+	if err == nil {
+		i.responseSize.Record(ctx, int64(messageSize(reply)), measured)
+	}
+	return err
+}
+
+// keyCount returns number of keys returned or affected by the call.
+func keyCount(reply any) (int64, bool) {
+	switch r := reply.(type) {
+	case *regattapb.RangeResponse:
+		if len(r.Kvs) == 0 {
+			return r.Count, true
+		}
+		return int64(len(r.Kvs)), true
+	case *regattapb.PutResponse:
+		return 1, true
+	case *regattapb.DeleteRangeResponse:
+		return r.Deleted, true
+	default:
+		return 0, false
+	}
+}
+
+// metadataCarrier adapts gRPC metadata to OpenTelemetry propagation carrier.
+type metadataCarrier metadata.MD
+
+func (m metadataCarrier) Get(key string) string {
+	values := metadata.MD(m).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (m metadataCarrier) Set(key, value string) {
+	metadata.MD(m).Set(key, value)
+}
+
+func (m metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jamf/regatta/regattapb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+func Test_instrumentation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "regatta-client range")
+	parent.End()
+	instr, err := newInstrumentation(tp, mp, trace.SpanContextFromContext(ctx))
+	require.NoError(t, err)
+
+	var traceparent string
+	invoker := func(ctx context.Context, _ string, _, reply any, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		traceparent = metadataCarrier(md).Get("traceparent")
+		proto.Merge(reply.(proto.Message), &regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("key1")}, {Key: []byte("key2")}}})
+		return nil
+	}
+
+	err = instr.intercept(context.Background(), "/regatta.v1.KV/Range", &regattapb.RangeRequest{Table: []byte("table")}, &regattapb.RangeResponse{}, nil, invoker)
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	span := spans[1]
+	assert.Equal(t, "regatta.v1.KV/Range", span.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+	assert.Contains(t, span.Attributes(), attribute.String("rpc.service", "regatta.v1.KV"))
+	assert.Contains(t, span.Attributes(), attribute.String("rpc.method", "Range"))
+	assert.Contains(t, span.Attributes(), attribute.String("regatta.table", "table"))
+	assert.Contains(t, span.Attributes(), attribute.Int64("regatta.key_count", 2))
+	assert.Equal(t, "00-"+span.SpanContext().TraceID().String()+"-"+span.SpanContext().SpanID().String()+"-01", traceparent)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	var names []string
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		names = append(names, m.Name)
+	}
+	assert.ElementsMatch(t, []string{"rpc.client.duration", "rpc.client.request.size", "rpc.client.response.size"}, names)
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	TLS.AddCommand(&TLSInspect)
+}
+
+// TLS is a subcommand grouping commands used for diagnosing TLS connections to Regatta.
+var TLS = cobra.Command{
+	Use:   "tls",
+	Short: "Diagnose TLS connection to Regatta",
+}
+
+// TLSInspect is a subcommand used for printing certificate chain presented by Regatta.
+var TLSInspect = cobra.Command{
+	Use:   "inspect",
+	Short: "Print certificate chain presented by Regatta",
+	Long: "Connects to all endpoints and prints negotiated TLS version, cipher suite and ALPN protocol together with the certificate chain presented by Regatta.\n" +
+		"The chain is verified using trusted CA certificates and pinned public keys, regardless of --insecure flag, " +
+		"the result is shown in \"verified\" and \"verification_error\" fields. " +
+		"Field \"sha256_spki\" of each certificate contains the hash of its public key, which can be used with --pin-sha256 flag. " +
+		"The command exits with non-zero status, when connection to any endpoint fails.",
+	Example: "regatta-client tls inspect --endpoint regatta.example.com:443\n" +
+		"regatta-client tls inspect --endpoint regatta.example.com:443 --no-system-ca --cert ca.crt",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, _ []string) {
+		endpoints, err := parseEndpoints(endpointOption)
+		if err != nil {
+			printError(cmd, "There was an error while decoding parameters.", err)
+			return
+		}
+		cfg, err := newTLSConfig()
+		if err != nil {
+			printError(cmd, "There was an error while loading certificates.", err)
+			return
+		}
+		pins, err := parsePins(pinsOption)
+		if err != nil {
+			printError(cmd, "There was an error while decoding parameters.", err)
+			return
+		}
+
+		results := make([]tlsInspectResult, 0, len(endpoints))
+		for _, endpoint := range endpoints {
+			ctx, cancel := context.WithTimeout(cmd.Context(), 10*time.Second)
+			result := inspectTLS(ctx, endpoint, cfg, pins)
+			cancel()
+			if len(result.Error) != 0 {
+				exitStatus = 1
+			}
+			results = append(results, result)
+		}
+		marshal, _ := json.Marshal(results)
+		cmd.Println(string(marshal))
+	},
+}
+
+type tlsInspectResult struct {
+	Endpoint          string            `json:"endpoint"`
+	ServerName        string            `json:"server_name"`
+	Version           string            `json:"version,omitempty"`
+	CipherSuite       string            `json:"cipher_suite,omitempty"`
+	ALPN              string            `json:"alpn,omitempty"`
+	Chain             []certificateInfo `json:"chain,omitempty"`
+	Verified          bool              `json:"verified"`
+	VerificationError string            `json:"verification_error,omitempty"`
+	Error             string            `json:"error,omitempty"`
+}
+
+type certificateInfo struct {
+	Subject           string    `json:"subject"`
+	Issuer            string    `json:"issuer"`
+	SerialNumber      string    `json:"serial_number"`
+	NotBefore         time.Time `json:"not_before"`
+	NotAfter          time.Time `json:"not_after"`
+	DNSNames          []string  `json:"dns_names,omitempty"`
+	IPAddresses       []string  `json:"ip_addresses,omitempty"`
+	IsCA              bool      `json:"is_ca,omitempty"`
+	SHA256SPKI        string    `json:"sha256_spki"`
+	SHA256Fingerprint string    `json:"sha256_fingerprint"`
+}
+
+func newCertificateInfo(cert *x509.Certificate) certificateInfo {
+	spki := spkiHash(cert)
+	fingerprint := sha256.Sum256(cert.Raw)
+	info := certificateInfo{
+		Subject:           cert.Subject.String(),
+		Issuer:            cert.Issuer.String(),
+		SerialNumber:      cert.SerialNumber.String(),
+		NotBefore:         cert.NotBefore,
+		NotAfter:          cert.NotAfter,
+		DNSNames:          cert.DNSNames,
+		IsCA:              cert.IsCA,
+		SHA256SPKI:        base64.StdEncoding.EncodeToString(spki[:]),
+		SHA256Fingerprint: hex.EncodeToString(fingerprint[:]),
+	}
+	for _, ip := range cert.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	return info
+}
+
+// inspectTLS performs TLS handshake with the endpoint and verifies the presented certificate chain.
+func inspectTLS(ctx context.Context, endpoint string, cfg *tls.Config, pins [][]byte) tlsInspectResult {
+	result := tlsInspectResult{Endpoint: endpoint, ServerName: endpointServerName(endpoint)}
+	conn, err := dialEndpoint(ctx, endpoint)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer conn.Close()
+	if err := handshakeTLS(ctx, conn, &result, cfg, pins); err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// handshakeTLS performs TLS handshake over the connection and records negotiated parameters together with the result
+// of certificate chain verification into the result.
+func handshakeTLS(ctx context.Context, conn net.Conn, result *tlsInspectResult, cfg *tls.Config, pins [][]byte) error {
+	// the chain is verified separately, so that the chain is recorded even when it is not trusted
+	inspectCfg := cfg.Clone()
+	inspectCfg.ServerName = result.ServerName
+	inspectCfg.InsecureSkipVerify = true // nolint:gosec
+	inspectCfg.VerifyConnection = nil
+	inspectCfg.NextProtos = []string{"h2"}
+	tlsConn := tls.Client(conn, inspectCfg)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return err
+	}
+
+	state := tlsConn.ConnectionState()
+	result.Version = tls.VersionName(state.Version)
+	result.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+	result.ALPN = state.NegotiatedProtocol
+	for _, cert := range state.PeerCertificates {
+		result.Chain = append(result.Chain, newCertificateInfo(cert))
+	}
+	if err := verifyChain(state.PeerCertificates, cfg.RootCAs, result.ServerName, pins); err != nil {
+		result.VerificationError = err.Error()
+	} else {
+		result.Verified = true
+	}
+	return nil
+}
+
+// verifyChain verifies the certificate chain presented by the server using trusted CA certificates and pinned public keys.
+func verifyChain(chain []*x509.Certificate, roots *x509.CertPool, serverName string, pins [][]byte) error {
+	if len(chain) == 0 {
+		return errors.New("no certificate presented")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	chains, err := chain[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates, DNSName: serverName})
+	if err != nil {
+		return err
+	}
+	if len(pins) != 0 {
+		return verifyPins(chains, pins)
+	}
+	return nil
+}
+
+// dialEndpoint opens TCP or Unix domain socket connection to the endpoint, using proxy when selected.
+func dialEndpoint(ctx context.Context, endpoint string) (net.Conn, error) {
+	proxyURL, err := endpointProxy(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return proxyContextDialer(proxyURL)(ctx, endpointAddress(endpoint))
+}
+
+// endpointAddress returns address of the endpoint in a form accepted by the context dialer.
+func endpointAddress(endpoint string) string {
+	switch {
+	case strings.HasPrefix(endpoint, "unix-abstract:"):
+		return "\x00" + strings.TrimPrefix(endpoint, "unix-abstract:")
+	case isUnixEndpoint(endpoint):
+		return endpoint
+	case strings.Contains(endpoint, "://"):
+		// e.g. dns:///regatta.example.com:443
+		return endpoint[strings.LastIndex(endpoint, "/")+1:]
+	default:
+		return endpoint
+	}
+}
+
+// endpointServerName returns name of the server used for verification of its certificate.
+func endpointServerName(endpoint string) string {
+	if isUnixEndpoint(endpoint) {
+		return "localhost"
+	}
+	addr := endpointAddress(endpoint)
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/jamf/regatta/regattapb"
+	"github.com/jamf/regatta/regattaserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+func Test_TLSInspect(t *testing.T) {
+	defer resetTLSFlags()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: new(mockKVService)})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	tests := []struct {
+		name                  string
+		args                  []string
+		wantVerified          bool
+		wantVerificationError string
+	}{
+		{
+			name:         "trusted certificate",
+			args:         []string{"--cert", "test.crt"},
+			wantVerified: true,
+		},
+		{
+			name:                  "untrusted certificate",
+			args:                  []string{"--cert", "", "--no-system-ca"},
+			wantVerificationError: "x509: certificate signed by unknown authority",
+		},
+		{
+			name:                  "pinned public key mismatch",
+			args:                  []string{"--cert", "test.crt", "--pin-sha256", "sha256//47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="},
+			wantVerificationError: "none of the server certificates matches pinned public keys",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetTLSFlags()
+
+			buf := new(bytes.Buffer)
+			RootCmd.SetOut(buf)
+			RootCmd.SetArgs(append([]string{"tls", "inspect", "--endpoint", lis.Addr().String()}, tt.args...))
+			RootCmd.Execute()
+
+			var results []tlsInspectResult
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &results))
+			require.Len(t, results, 1)
+			result := results[0]
+			assert.Empty(t, result.Error)
+			assert.Equal(t, "127.0.0.1", result.ServerName)
+			assert.Equal(t, "TLS 1.3", result.Version)
+			assert.Equal(t, "h2", result.ALPN)
+			require.Len(t, result.Chain, 1)
+			assert.Equal(t, []string{"localhost"}, result.Chain[0].DNSNames)
+			assert.Equal(t, []string{"127.0.0.1"}, result.Chain[0].IPAddresses)
+			assert.Equal(t, tt.wantVerified, result.Verified)
+			assert.Equal(t, tt.wantVerificationError, result.VerificationError)
+		})
+	}
+}
+
+func Test_endpointAddress(t *testing.T) {
+	tests := []struct {
+		endpoint       string
+		wantAddress    string
+		wantServerName string
+	}{
+		{endpoint: "regatta.example.com:443", wantAddress: "regatta.example.com:443", wantServerName: "regatta.example.com"},
+		{endpoint: "dns:///regatta.example.com:443", wantAddress: "regatta.example.com:443", wantServerName: "regatta.example.com"},
+		{endpoint: "unix:///var/run/regatta.sock", wantAddress: "unix:///var/run/regatta.sock", wantServerName: "localhost"},
+		{endpoint: "unix-abstract:regatta", wantAddress: "\x00regatta", wantServerName: "localhost"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			assert.Equal(t, tt.wantAddress, endpointAddress(tt.endpoint))
+			assert.Equal(t, tt.wantServerName, endpointServerName(tt.endpoint))
+		})
+	}
+}
+
+func resetTLSFlags() {
+	noSystemCAOption = false
+	caDirOption = ""
+	pinsOption = nil
+}
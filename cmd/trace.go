@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jamf/regatta/regattapb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// tracer prints outgoing requests together with the outcome, latency, sizes and the Regatta response header of each call.
+// In debug mode also the metadata and whole responses are printed.
+type tracer struct {
+	out   io.Writer
+	debug bool
+}
+
+func (t *tracer) intercept(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	var header, trailer metadata.MD
+	p := &peer.Peer{}
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Header(&header), grpc.Trailer(&trailer), grpc.Peer(p))...)
+	elapsed := time.Since(start)
+
+	// the whole trace is written at once, so that traces of concurrent calls are not interleaved
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "> %s %s\n", method, messageJSON(req))
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && t.debug {
+		fmt.Fprintf(buf, "> metadata %s\n", metadataJSON(md))
+	}
+
+	fmt.Fprintf(buf, "< %s %s in %s", method, status.Code(err), elapsed)
+	if p.Addr != nil {
+		fmt.Fprintf(buf, ", endpoint %s", p.Addr)
+	}
+	fmt.Fprintf(buf, ", compressor %s, request %d B", usedCompressor(opts), messageSize(req))
+	if err != nil {
+		fmt.Fprintf(buf, ", error %q\n", status.Convert(err).Message())
+	} else {
+		fmt.Fprintf(buf, ", response %d B", messageSize(reply))
+		if h, ok := reply.(interface {
+			GetHeader() *regattapb.ResponseHeader
+		}); ok && h.GetHeader() != nil {
+			rh := h.GetHeader()
+			fmt.Fprintf(buf, ", shard %d, replica %d, revision %d, raft term %d, raft leader %d",
+				rh.ShardId, rh.ReplicaId, rh.Revision, rh.RaftTerm, rh.RaftLeaderId)
+		}
+		buf.WriteString("\n")
+	}
+
+	if t.debug {
+		if len(header) != 0 {
+			fmt.Fprintf(buf, "< header %s\n", metadataJSON(header))
+		}
+		if len(trailer) != 0 {
+			fmt.Fprintf(buf, "< trailer %s\n", metadataJSON(trailer))
+		}
+		if err == nil {
+			fmt.Fprintf(buf, "< response %s\n", messageJSON(reply))
+		}
+	}
+	_, _ = t.out.Write(buf.Bytes())
+	return err
+}
+
+// usedCompressor returns name of the compressor selected for the call.
+func usedCompressor(opts []grpc.CallOption) string {
+	for _, opt := range opts {
+		if c, ok := opt.(grpc.CompressorCallOption); ok {
+			return c.CompressorType
+		}
+	}
+	return noCompressName
+}
+
+func messageSize(m any) int {
+	if pm, ok := m.(proto.Message); ok {
+		return proto.Size(pm)
+	}
+	return 0
+}
+
+// sensitiveMetadata are keys of metadata containing credentials, their values are redacted in the trace.
+var sensitiveMetadata = []string{"authorization", "proxy-authorization", "cookie"}
+
+// metadataJSON serializes the metadata into JSON, values of sensitive metadata are replaced by their lengths.
+func metadataJSON(md metadata.MD) string {
+	redacted := md.Copy()
+	for _, key := range sensitiveMetadata {
+		values := redacted.Get(key)
+		for i, v := range values {
+			values[i] = fmt.Sprintf("[redacted %d B]", len(v))
+		}
+	}
+	marshal, _ := json.Marshal(redacted)
+	return string(marshal)
+}
+
+// messageJSON serializes the protobuf message into JSON, unlike protojson, bytes fields are serialized as escaped strings,
+// so that keys and ranges sent to Regatta are readable.
+func messageJSON(m any) string {
+	pm, ok := m.(proto.Message)
+	if !ok {
+		return "{}"
+	}
+	marshal, _ := json.Marshal(messageValue(pm.ProtoReflect()))
+	return string(marshal)
+}
+
+func messageValue(m protoreflect.Message) map[string]any {
+	result := make(map[string]any)
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList():
+			list := make([]any, 0, v.List().Len())
+			for i := 0; i < v.List().Len(); i++ {
+				list = append(list, fieldValue(fd, v.List().Get(i)))
+			}
+			result[string(fd.Name())] = list
+		case fd.IsMap():
+			entries := make(map[string]any)
+			v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+				entries[k.String()] = fieldValue(fd.MapValue(), mv)
+				return true
+			})
+			result[string(fd.Name())] = entries
+		default:
+			result[string(fd.Name())] = fieldValue(fd, v)
+		}
+		return true
+	})
+	return result
+}
+
+func fieldValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	switch fd.Kind() {
+	case protoreflect.BytesKind:
+		return escape(v.Bytes())
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return v.Enum()
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return messageValue(v.Message())
+	default:
+		return v.Interface()
+	}
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/jamf/regatta/regattapb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func Test_tracer(t *testing.T) {
+	tests := []struct {
+		name  string
+		debug bool
+		err   error
+		want  string
+	}{
+		{
+			name: "verbose",
+			want: `> /regatta.v1.KV/Range {"key":"prefix","range_end":"prefiy","table":"table"}
+< /regatta.v1.KV/Range OK in <latency>, compressor gzip, request 23 B, response 23 B, shard 1, replica 2, revision 10, raft term 3, raft leader 2
+`,
+		},
+		{
+			name:  "debug",
+			debug: true,
+			want: `> /regatta.v1.KV/Range {"key":"prefix","range_end":"prefiy","table":"table"}
+> metadata {"authorization":["[redacted 12 B]"],"cookie":["[redacted 9 B]"],"x-tenant":["tenant"]}
+< /regatta.v1.KV/Range OK in <latency>, compressor gzip, request 23 B, response 23 B, shard 1, replica 2, revision 10, raft term 3, raft leader 2
+< response {"header":{"raft_leader_id":2,"raft_term":3,"replica_id":2,"revision":10,"shard_id":1},"kvs":[{"key":"prefix\\0"}]}
+`,
+		},
+		{
+			name: "error",
+			err:  status.Error(codes.Unavailable, "leader election"),
+			want: `> /regatta.v1.KV/Range {"key":"prefix","range_end":"prefiy","table":"table"}
+< /regatta.v1.KV/Range Unavailable in <latency>, compressor gzip, request 23 B, error "leader election"
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			tr := &tracer{out: buf, debug: tt.debug}
+			invoker := func(_ context.Context, _ string, _, reply any, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+				if tt.err != nil {
+					return tt.err
+				}
+				proto.Merge(reply.(proto.Message), &regattapb.RangeResponse{
+					Header: &regattapb.ResponseHeader{ShardId: 1, ReplicaId: 2, Revision: 10, RaftTerm: 3, RaftLeaderId: 2},
+					Kvs:    []*regattapb.KeyValue{{Key: []byte("prefix\x00")}},
+				})
+				return nil
+			}
+			ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("authorization", "Bearer token", "cookie", "session=1", "x-tenant", "tenant"))
+			req := &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("prefix"), RangeEnd: []byte("prefiy")}
+
+			err := tr.intercept(ctx, "/regatta.v1.KV/Range", req, &regattapb.RangeResponse{}, nil, invoker, grpc.UseCompressor(gzipCompress.String()))
+
+			assert.Equal(t, tt.err, err)
+			latency := regexp.MustCompile(`in [0-9.]+[µnm]?s,`)
+			assert.Equal(t, tt.want, latency.ReplaceAllString(buf.String(), "in <latency>,"))
+		})
+	}
+}
+
+func Test_messageJSON(t *testing.T) {
+	req := &regattapb.TxnRequest{
+		Table: []byte("table"),
+		Compare: []*regattapb.Compare{
+			{Key: []byte("key"), Result: regattapb.Compare_GREATER, TargetUnion: &regattapb.Compare_Value{Value: []byte{0xff}}},
+		},
+	}
+
+	require.Equal(t, `{"compare":[{"key":"key","result":"GREATER","value":"\\xff"}],"table":"table"}`, messageJSON(req))
+}
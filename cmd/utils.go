@@ -1,19 +1,16 @@
 package cmd
 
-func findNextString(str string) string {
-	// Convert string to byte slice for mutation
-	bytes := []byte(str)
-
-	// Start from the last character and increment its byte value
-	i := len(bytes) - 1
-	for i >= 0 {
-		if bytes[i] < 255 {
-			bytes[i]++
-			break
+// prefixRangeEnd returns the end of the range containing all keys with the given prefix, that is the smallest key
+// greater than all keys with the prefix. Trailing 0xff bytes are dropped and the last remaining byte is incremented,
+// when the prefix consists of 0xff bytes only, the range ends at the end of the table.
+func prefixRangeEnd(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] < 0xff {
+			end := make([]byte, i+1)
+			copy(end, prefix)
+			end[i]++
+			return end
 		}
-		bytes[i] = 0
-		i--
 	}
-
-	return string(bytes)
+	return zero
 }
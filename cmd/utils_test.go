@@ -4,10 +4,14 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"net"
 	"os"
+	"path/filepath"
+	"testing"
 
 	"github.com/jamf/regatta/regattapb"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func generateTLSConfig() *tls.Config {
@@ -34,6 +38,20 @@ func generateTLSConfig() *tls.Config {
 	}
 }
 
+// listen creates listener for test server using the given network ("tcp" or "unix"),
+// together with the endpoint the client should connect to.
+func listen(t *testing.T, network string) (net.Listener, string) {
+	if network == "unix" {
+		socket := filepath.Join(t.TempDir(), "regatta.sock")
+		lis, err := net.Listen("unix", socket)
+		require.NoError(t, err)
+		return lis, "unix://" + socket
+	}
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	return lis, lis.Addr().String()
+}
+
 type mockKVService struct {
 	mock.Mock
 }
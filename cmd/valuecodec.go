@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/spf13/cobra"
+)
+
+var (
+	noValueCodecName     = "none"
+	autoValueCodecName   = "auto"
+	gzipValueCodecName   = "gzip"
+	zstdValueCodecName   = "zstd"
+	snappyValueCodecName = "snappy"
+	lz4ValueCodecName    = "lz4"
+	noValueCodec         = valueCodecType(noValueCodecName)
+
+	gzipMagic   = []byte{0x1f, 0x8b}
+	zstdMagic   = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	lz4Magic    = []byte{0x04, 0x22, 0x4d, 0x18}
+	snappyMagic = []byte{0xff, 0x06, 0x00, 0x00, 's', 'N', 'a', 'P', 'p', 'Y'}
+)
+
+// valueCodecType is a compression algorithm used for compressing values stored in Regatta.
+type valueCodecType string
+
+func (c *valueCodecType) String() string {
+	return string(*c)
+}
+
+func (c *valueCodecType) Set(v string) error {
+	switch v {
+	case gzipValueCodecName, zstdValueCodecName, snappyValueCodecName, lz4ValueCodecName, autoValueCodecName, noValueCodecName:
+		*c = valueCodecType(v)
+		return nil
+	default:
+		return errors.New(`must be one of "gzip", "zstd", "snappy", "lz4", "auto" or "none"`)
+	}
+}
+
+func (c *valueCodecType) Type() string {
+	return "valueCodecType"
+}
+
+func valueCodecTypeCompletion(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	return []string{
+		"gzip\tgzip compressed values",
+		"zstd\tzstd compressed values",
+		"snappy\tsnappy compressed values, block format is used for writing, both block and framed formats are supported for reading",
+		"lz4\tlz4 compressed values in frame format",
+		"auto\tdetect compression of read values using magic bytes",
+		"none\tno compression",
+	}, cobra.ShellCompDirectiveDefault
+}
+
+// compress compresses the given value.
+func (c *valueCodecType) compress(data []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	var w io.WriteCloser
+	switch c.String() {
+	case gzipValueCodecName:
+		w = gzip.NewWriter(buf)
+	case zstdValueCodecName:
+		enc, err := zstd.NewWriter(buf)
+		if err != nil {
+			return nil, err
+		}
+		w = enc
+	case lz4ValueCodecName:
+		w = lz4.NewWriter(buf)
+	case snappyValueCodecName:
+		return snappy.Encode(nil, data), nil
+	case autoValueCodecName:
+		return nil, errors.New(`"auto" value codec can be used only for reading values`)
+	default:
+		return data, nil
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress decompresses the given value, with "auto" codec the compression is detected using magic bytes
+// and values without known magic bytes are returned unchanged. When decompression fails, the original value is returned with the error.
+func (c *valueCodecType) decompress(data []byte) ([]byte, error) {
+	codec := c.String()
+	if codec == autoValueCodecName {
+		codec = detectValueCodec(data)
+	}
+	var r io.Reader
+	switch codec {
+	case gzipValueCodecName:
+		gr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return data, err
+		}
+		r = gr
+	case zstdValueCodecName:
+		dec, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return data, err
+		}
+		defer dec.Close()
+		r = dec
+	case lz4ValueCodecName:
+		r = lz4.NewReader(bytes.NewReader(data))
+	case snappyValueCodecName:
+		if bytes.HasPrefix(data, snappyMagic) {
+			r = snappy.NewReader(bytes.NewReader(data))
+			break
+		}
+		decoded, err := snappy.Decode(nil, data)
+		if err != nil {
+			return data, err
+		}
+		return decoded, nil
+	default:
+		return data, nil
+	}
+	decompressed, err := io.ReadAll(r)
+	if err != nil {
+		return data, err
+	}
+	return decompressed, nil
+}
+
+func detectValueCodec(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		return gzipValueCodecName
+	case bytes.HasPrefix(data, zstdMagic):
+		return zstdValueCodecName
+	case bytes.HasPrefix(data, lz4Magic):
+		return lz4ValueCodecName
+	case bytes.HasPrefix(data, snappyMagic):
+		return snappyValueCodecName
+	default:
+		return noValueCodecName
+	}
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_valueCodecType_RoundTrip(t *testing.T) {
+	data := []byte("some data, some data, some data")
+	for _, name := range []string{gzipValueCodecName, zstdValueCodecName, snappyValueCodecName, lz4ValueCodecName, noValueCodecName} {
+		t.Run(name, func(t *testing.T) {
+			codec := valueCodecType(name)
+
+			compressed, err := codec.compress(data)
+			require.NoError(t, err)
+			decompressed, err := codec.decompress(compressed)
+			require.NoError(t, err)
+
+			assert.Equal(t, data, decompressed)
+		})
+	}
+}
+
+func Test_valueCodecType_DecompressAuto(t *testing.T) {
+	data := []byte("some data")
+	codec := valueCodecType(autoValueCodecName)
+	for _, name := range []string{gzipValueCodecName, zstdValueCodecName, lz4ValueCodecName} {
+		t.Run(name, func(t *testing.T) {
+			compressor := valueCodecType(name)
+			compressed, err := compressor.compress(data)
+			require.NoError(t, err)
+
+			decompressed, err := codec.decompress(compressed)
+
+			require.NoError(t, err)
+			assert.Equal(t, data, decompressed)
+		})
+	}
+	t.Run("uncompressed", func(t *testing.T) {
+		decompressed, err := codec.decompress(data)
+
+		require.NoError(t, err)
+		assert.Equal(t, data, decompressed)
+	})
+}
+
+func Test_valueCodecType_CompressAuto(t *testing.T) {
+	codec := valueCodecType(autoValueCodecName)
+
+	_, err := codec.compress([]byte("some data"))
+
+	require.Error(t, err)
+}
@@ -0,0 +1,347 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jamf/regatta/regattapb"
+	"github.com/spf13/cobra"
+)
+
+const (
+	watchEventAdded   = "added"
+	watchEventChanged = "changed"
+	watchEventDeleted = "deleted"
+)
+
+var (
+	watchBinary      bool
+	watchInterval    time.Duration
+	watchExec        string
+	watchDebounce    time.Duration
+	watchConcurrency int
+)
+
+func init() {
+	Watch.Flags().BoolVar(&watchBinary, "binary", false, "avoid decoding keys and values into UTF-8 strings, but rather encode them as Base64 strings")
+	Watch.Flags().DurationVar(&watchInterval, "interval", time.Second, "interval between polls of Regatta")
+	Watch.Flags().StringVar(&watchExec, "exec", "", "shell command executed for each change")
+	Watch.Flags().DurationVar(&watchDebounce, "debounce", 0, "execute the command only after the item did not change for the given duration")
+	Watch.Flags().IntVar(&watchConcurrency, "concurrency", 1, "maximum number of concurrently executed commands")
+}
+
+// Watch is a subcommand used for watching changes of records in a table.
+var Watch = cobra.Command{
+	Use:   "watch <table> [key]",
+	Short: "Watch changes of data in Regatta store",
+	Long: "Watches changes of data in Regatta store by repeatedly querying it using Range query as defined in API (https://engineering.jamf.com/regatta/api/#range).\n" +
+		"You can either watch all items in the table by providing no key.\n" +
+		"Or you can watch a single item by providing item's key.\n" +
+		"Or you can watch all items with given prefix, by providing the given prefix and adding the asterisk (*) to the prefix.\n" +
+		"Each change is printed as JSON object on a separate line, with \"time\" field containing the time, when the change was observed, " +
+		"\"type\" field containing either \"added\", \"changed\" or \"deleted\" and the fields of the item as printed by range command. " +
+		"Deleted items contain the last observed value.\n" +
+		"Changes are detected by comparing consecutive results of the polls, so changes made between two polls, that cancel each other out, are not observed.\n" +
+		"When --exec flag is provided, the shell command is executed for each change. The change is passed to the command as JSON object on standard input " +
+		"and in environment variables REGATTA_EVENT, REGATTA_TABLE, REGATTA_KEY, REGATTA_VALUE and REGATTA_MOD_REVISION, " +
+		"keys and values are encoded in the same way as in the printed changes, except keys and values containing NUL bytes, " +
+		"which cannot be passed in environment variables, they are escaped and REGATTA_KEY_ENCODING or REGATTA_VALUE_ENCODING is set to \"escaped\". Output of the command is written to standard error. " +
+		"With --debounce flag, the command is executed only for the last change of the item, after the item did not change for the given duration. " +
+		"Number of concurrently executed commands is limited by --concurrency flag.",
+	Example: "regatta-client watch table\n" +
+		"regatta-client watch table key\n" +
+		"regatta-client watch --interval 5s table 'prefix*'\n" +
+		"regatta-client watch --exec 'systemctl reload service' --debounce 10s table 'prefix*'",
+	Args: cobra.MatchAll(cobra.MinimumNArgs(1), cobra.MaximumNArgs(2)),
+	Run: func(cmd *cobra.Command, args []string) {
+		if watchInterval <= 0 {
+			printError(cmd, "There was an error while decoding parameters. The --interval must be a positive duration.")
+			return
+		}
+
+		req, err := createWatchRangeRequest(args)
+		if err != nil {
+			printError(cmd, "There was an error while decoding parameters.", err)
+			return
+		}
+
+		client, err := createClient()
+		if err != nil {
+			printError(cmd, "There was an error, while establishing connection to Regatta.", err)
+			return
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		var executor *eventExecutor
+		if len(watchExec) != 0 {
+			executor = newEventExecutor(watchExec, string(req.Table), watchDebounce, watchConcurrency, cmd.ErrOrStderr())
+			defer executor.close()
+		}
+
+		err = watchRange(ctx, client, req, watchInterval, func(event watchEvent) {
+			marshal, _ := json.Marshal(event)
+			cmd.Println(string(marshal))
+			if executor != nil {
+				executor.submit(ctx, event)
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			handleRegattaError(cmd, err)
+		}
+	},
+}
+
+type watchEvent struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+	rangeCommandResult
+	kv *regattapb.KeyValue
+}
+
+func createWatchRangeRequest(args []string) (*regattapb.RangeRequest, error) {
+	// watch all
+	req := &regattapb.RangeRequest{
+		Table:    []byte(args[0]),
+		Key:      zero,
+		RangeEnd: zero,
+	}
+	if len(args) == 2 {
+		// watch single or by prefix
+		var err error
+		req.Key, req.RangeEnd, err = parseKeyRange(args[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// watchRange polls Regatta in the given interval and calls onEvent for each item, that was added, changed or deleted since the previous poll.
+// Items present in the first poll are not reported. It returns when the context is done or when querying Regatta fails.
+func watchRange(ctx context.Context, client regattapb.KVClient, req *regattapb.RangeRequest, interval time.Duration, onEvent func(watchEvent)) error {
+	var previous map[string]*regattapb.KeyValue
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		current, err := pollRange(ctx, client, req)
+		if err != nil {
+			return err
+		}
+		if previous != nil {
+			for _, event := range diffRange(previous, current, time.Now()) {
+				onEvent(event)
+			}
+		}
+		previous = current
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func pollRange(ctx context.Context, client regattapb.KVClient, req *regattapb.RangeRequest) (map[string]*regattapb.KeyValue, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	// copy the request, because retrieving all pages modifies it
+	kvs, err := rangeAll(timeoutCtx, client, &regattapb.RangeRequest{Table: req.Table, Key: req.Key, RangeEnd: req.RangeEnd})
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[string]*regattapb.KeyValue, len(kvs))
+	for _, kv := range kvs {
+		items[string(kv.Key)] = kv
+	}
+	return items, nil
+}
+
+// diffRange creates events describing differences between two polls, items are considered changed, when their modification revision
+// or value differ.
+func diffRange(previous, current map[string]*regattapb.KeyValue, now time.Time) []watchEvent {
+	var events []watchEvent
+	for key, kv := range current {
+		prev, ok := previous[key]
+		switch {
+		case !ok:
+			events = append(events, newWatchEvent(now, watchEventAdded, kv))
+		case prev.ModRevision != kv.ModRevision || !bytes.Equal(prev.Value, kv.Value):
+			events = append(events, newWatchEvent(now, watchEventChanged, kv))
+		}
+	}
+	for key, kv := range previous {
+		if _, ok := current[key]; !ok {
+			events = append(events, newWatchEvent(now, watchEventDeleted, kv))
+		}
+	}
+	sortWatchEvents(events)
+	return events
+}
+
+func newWatchEvent(now time.Time, eventType string, kv *regattapb.KeyValue) watchEvent {
+	return watchEvent{Time: now, Type: eventType, rangeCommandResult: newRangeCommandResult(kv, watchBinary), kv: kv}
+}
+
+func sortWatchEvents(events []watchEvent) {
+	sort.Slice(events, func(i, j int) bool {
+		return bytes.Compare(events[i].kv.Key, events[j].kv.Key) < 0
+	})
+}
+
+// eventExecutor executes the shell command for watch events. Events of the same item can be debounced,
+// so the command is executed only for the last event, and number of concurrently executed commands is limited.
+type eventExecutor struct {
+	command  string
+	table    string
+	debounce time.Duration
+	out      io.Writer
+	sem      chan struct{}
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	closed  bool
+	pending map[string]*debouncedEvent
+}
+
+// debouncedEvent is the last event of an item waiting for its debounce timer. Each submitted event increments the generation,
+// so the callback of a timer, which fired while a newer event was being submitted, can recognize it is stale.
+type debouncedEvent struct {
+	event      watchEvent
+	timer      *time.Timer
+	generation uint64
+}
+
+func newEventExecutor(command, table string, debounce time.Duration, concurrency int, out io.Writer) *eventExecutor {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &eventExecutor{
+		command:  command,
+		table:    table,
+		debounce: debounce,
+		out:      &syncWriter{w: out},
+		sem:      make(chan struct{}, concurrency),
+		pending:  make(map[string]*debouncedEvent),
+	}
+}
+
+func (e *eventExecutor) submit(ctx context.Context, event watchEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.submitLocked(ctx, event)
+}
+
+// submitLocked submits the event, it must be called with the mutex held.
+func (e *eventExecutor) submitLocked(ctx context.Context, event watchEvent) {
+	if e.closed {
+		return
+	}
+	if e.debounce <= 0 {
+		e.start(ctx, event)
+		return
+	}
+
+	key := string(event.kv.Key)
+	d, ok := e.pending[key]
+	if ok {
+		// stopping the timer does not prevent the callback, which already fired and waits for the mutex
+		d.timer.Stop()
+	} else {
+		d = &debouncedEvent{}
+		e.pending[key] = d
+	}
+	d.event = event
+	d.generation++
+	generation := d.generation
+	d.timer = time.AfterFunc(e.debounce, func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if e.closed || e.pending[key] != d || d.generation != generation {
+			return
+		}
+		delete(e.pending, key)
+		e.start(ctx, d.event)
+	})
+}
+
+// start executes the command in a separate goroutine, it must be called with the mutex held.
+func (e *eventExecutor) start(ctx context.Context, event watchEvent) {
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		select {
+		case e.sem <- struct{}{}:
+			defer func() { <-e.sem }()
+		case <-ctx.Done():
+			return
+		}
+		if err := e.execute(ctx, event); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(e.out, "Command executed for key '%s' failed. %v\n", event.Key, err)
+		}
+	}()
+}
+
+func (e *eventExecutor) execute(ctx context.Context, event watchEvent) error {
+	input, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	// nolint:gosec
+	command := exec.CommandContext(ctx, "sh", "-c", e.command)
+	command.Env = append(os.Environ(),
+		"REGATTA_EVENT="+event.Type,
+		"REGATTA_TABLE="+e.table,
+		fmt.Sprint("REGATTA_MOD_REVISION=", event.ModRevision),
+	)
+	command.Env = appendEnvironment(command.Env, "REGATTA_KEY", event.Key, event.kv.Key)
+	command.Env = appendEnvironment(command.Env, "REGATTA_VALUE", fmt.Sprint(event.Value), event.kv.Value)
+	command.Stdin = bytes.NewReader(input)
+	command.Stdout = e.out
+	command.Stderr = e.out
+	return command.Run()
+}
+
+// appendEnvironment appends the environment variable with the encoded data. Environment variables cannot contain NUL bytes,
+// so when the encoded data contain them, the data are escaped instead and the variable with _ENCODING suffix is set to "escaped".
+func appendEnvironment(env []string, name, encoded string, data []byte) []string {
+	if !strings.Contains(encoded, "\x00") {
+		return append(env, name+"="+encoded)
+	}
+	return append(env, name+"="+escape(data), name+"_ENCODING="+string(escapedEncoding))
+}
+
+// close stops debounced events from being executed and waits for the running commands to finish.
+func (e *eventExecutor) close() {
+	e.mu.Lock()
+	e.closed = true
+	for _, d := range e.pending {
+		d.timer.Stop()
+	}
+	e.mu.Unlock()
+	e.wg.Wait()
+}
+
+// syncWriter serializes writes of concurrently executed commands.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
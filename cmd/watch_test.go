@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jamf/regatta/regattapb"
+	"github.com/jamf/regatta/regattaserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+func Test_diffRange(t *testing.T) {
+	now := time.Now()
+	previous := map[string]*regattapb.KeyValue{
+		"changed":   {Key: []byte("changed"), Value: []byte("old"), ModRevision: 1},
+		"deleted":   {Key: []byte("deleted"), Value: []byte("value"), ModRevision: 1},
+		"unchanged": {Key: []byte("unchanged"), Value: []byte("value"), ModRevision: 1},
+	}
+	current := map[string]*regattapb.KeyValue{
+		"added":     {Key: []byte("added"), Value: []byte("value"), ModRevision: 2},
+		"changed":   {Key: []byte("changed"), Value: []byte("new"), ModRevision: 2},
+		"unchanged": {Key: []byte("unchanged"), Value: []byte("value"), ModRevision: 1},
+	}
+
+	events := diffRange(previous, current, now)
+
+	require.Len(t, events, 3)
+	assert.Equal(t, watchEventAdded, events[0].Type)
+	assert.Equal(t, "added", events[0].Key)
+	assert.Equal(t, watchEventChanged, events[1].Type)
+	assert.Equal(t, "new", events[1].Value)
+	assert.Equal(t, watchEventDeleted, events[2].Type)
+	assert.Equal(t, "value", events[2].Value)
+}
+
+func Test_Watch(t *testing.T) {
+	watchInterval = 10 * time.Millisecond
+	defer func() { watchInterval = time.Second }()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(generateTLSConfig())))
+
+	storage := new(mockKVService)
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("key"), RangeEnd: []byte("kez")}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("key1"), Value: []byte("value1")}}}, nil).Once()
+	storage.On("Range", mock.Anything, &regattapb.RangeRequest{Table: []byte("table"), Key: []byte("key"), RangeEnd: []byte("kez")}).
+		Return(&regattapb.RangeResponse{Kvs: []*regattapb.KeyValue{{Key: []byte("key1"), Value: []byte("value2")}}}, nil)
+
+	regattapb.RegisterKVServer(s, &regattaserver.KVServer{Storage: storage})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	buf := new(bytes.Buffer)
+	RootCmd.SetOut(buf)
+	RootCmd.SetArgs([]string{"--endpoint", lis.Addr().String(), "--cert", "test.crt", "watch", "table", "key*"})
+	RootCmd.ExecuteContext(ctx)
+	RootCmd.SetContext(context.Background())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `"type":"changed","key":"key1","value":"value2"`)
+}
+
+func Test_Watch_InvalidInterval(t *testing.T) {
+	defer func() { watchInterval = time.Second }()
+
+	for _, interval := range []string{"0", "-1s"} {
+		t.Run(interval, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			errBuf := new(bytes.Buffer)
+			RootCmd.SetOut(buf)
+			RootCmd.SetErr(errBuf)
+			defer RootCmd.SetErr(nil)
+			RootCmd.SetArgs([]string{"--endpoint", "localhost:8443", "--cert", "test.crt", "watch", "--interval", interval, "table"})
+
+			assert.Equal(t, 1, executeCommand())
+			assert.Empty(t, buf.String())
+			assert.Equal(t, "There was an error while decoding parameters. The --interval must be a positive duration.", strings.TrimSpace(errBuf.String()))
+		})
+	}
+}
+
+func Test_eventExecutor(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	executor := newEventExecutor(`printf '%s %s %s %s ' "$REGATTA_EVENT" "$REGATTA_TABLE" "$REGATTA_KEY" "$REGATTA_VALUE" >> `+out+` && cat >> `+out, "table", 0, 1, new(bytes.Buffer))
+
+	executor.submit(context.Background(), newWatchEvent(time.Time{}, watchEventChanged, &regattapb.KeyValue{Key: []byte("key"), Value: []byte("value")}))
+	executor.close()
+
+	content, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, `changed table key value {"time":"0001-01-01T00:00:00Z","type":"changed","key":"key","value":"value"}`, string(content))
+}
+
+func Test_eventExecutor_BinaryKey(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	executor := newEventExecutor(`printf '%s %s %s %s' "$REGATTA_KEY" "$REGATTA_KEY_ENCODING" "$REGATTA_VALUE" "$REGATTA_VALUE_ENCODING" >> `+out, "table", 0, 1, new(bytes.Buffer))
+
+	// NUL bytes cannot be passed in environment variables, so they are escaped
+	executor.submit(context.Background(), newWatchEvent(time.Time{}, watchEventChanged, &regattapb.KeyValue{Key: []byte("key\x00\n"), Value: []byte("value")}))
+	executor.close()
+
+	content, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, `key\0\n escaped value `, string(content))
+}
+
+func Test_eventExecutor_Debounce(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	executor := newEventExecutor(`echo "$REGATTA_VALUE" >> `+out, "table", 50*time.Millisecond, 1, new(bytes.Buffer))
+
+	for _, value := range []string{"value1", "value2", "value3"} {
+		executor.submit(context.Background(), newWatchEvent(time.Time{}, watchEventChanged, &regattapb.KeyValue{Key: []byte("key"), Value: []byte(value)}))
+	}
+	time.Sleep(200 * time.Millisecond)
+	executor.close()
+
+	content, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "value3\n", string(content))
+}
+
+func Test_eventExecutor_Debounce_FiredTimer(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	executor := newEventExecutor(`echo "$REGATTA_VALUE" >> `+out, "table", 100*time.Millisecond, 1, new(bytes.Buffer))
+
+	executor.submit(context.Background(), newWatchEvent(time.Time{}, watchEventChanged, &regattapb.KeyValue{Key: []byte("key"), Value: []byte("value1")}))
+	// the timer fires while the next event is being submitted, so its callback waits for the mutex
+	executor.mu.Lock()
+	time.Sleep(150 * time.Millisecond)
+	executor.submitLocked(context.Background(), newWatchEvent(time.Time{}, watchEventChanged, &regattapb.KeyValue{Key: []byte("key"), Value: []byte("value2")}))
+	executor.mu.Unlock()
+
+	time.Sleep(30 * time.Millisecond)
+	_, err := os.Stat(out)
+	assert.ErrorIs(t, err, os.ErrNotExist, "the event must be debounced")
+
+	time.Sleep(200 * time.Millisecond)
+	executor.close()
+
+	content, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "value2\n", string(content))
+}